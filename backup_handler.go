@@ -2,23 +2,26 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
+	"log"
 	"net/http"
-	"time"
 )
 
+// BackupData is the /api/backup format. Checks are only set in full backups
+// made before streaming backups were added.
 type BackupData struct {
-	Hosts         []string                `json:"hosts"`
-	Notifications []StateChangeParams     `json:"notifications"`
-	Checks        map[string][]ChecksData `json:"checks,omitempty"`
+	Hosts          []string                `json:"hosts"`
+	Notifications  []StateChangeParams     `json:"notifications"`
+	Settings       []HostSettings          `json:"settings,omitempty"`
+	Meta           []HostMeta              `json:"meta,omitempty"`
+	Paused         []HostPause             `json:"paused,omitempty"`
+	Maintenance    []MaintenanceWindow     `json:"maintenance,omitempty"`
+	PushMonitors   []PushMonitor           `json:"push_monitors,omitempty"`
+	HTTPAssertions []HTTPAssertions        `json:"http_assertions,omitempty"`
+	Checks         map[string][]ChecksData `json:"checks,omitempty"`
 }
 
-//Go max time.Time
-//var maxTime time.Time = time.Unix(1<<63-62135596801, 999999999)
-//PostgreSQL max timestamp value
-var minTime time.Time = time.Unix(0, 0)
-var maxTime time.Time = time.Unix(866459203200, 0)
-
 const JsonBackupHandlerEndpoint string = "/api/backup"
 
 func JsonBackupHandler(w http.ResponseWriter, r *http.Request) {
@@ -39,16 +42,8 @@ func JsonBackupHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var err error
-	var buData BackupData
-
 	if r.Method == http.MethodGet {
-		buData.Hosts, err = MonData.GetHostsList()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		buData.Notifications, err = MonData.GetHostStateChangeParamsList()
+		buData, err := getBackupConfig(MonData)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -71,31 +66,29 @@ func JsonBackupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method == http.MethodPost || r.Method == http.MethodPut {
-		var body []byte
-		body, err = ioutil.ReadAll(r.Body)
+		body, err := ioutil.ReadAll(r.Body)
 		if err != nil {
 			http.Error(w, "Bad request", http.StatusBadRequest)
 			return
 		}
 
+		var buData BackupData
 		err = json.Unmarshal(body, &buData)
 		if err != nil {
 			http.Error(w, "Bad request", http.StatusBadRequest)
 			return
 		}
-		for _, h := range buData.Hosts {
-			err = AddHost(h)
-			if err != nil && err != ErrHostInDB {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+		err = restoreBackupConfig(MonData, buData)
+		reloadSchedule()
+		reloadPushTokens()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
-		for _, n := range buData.Notifications {
-			err = MonData.AddHostStateChangeParams(n.Host, n.ChangeThreshold, n.Action)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+		err = loadMaintenanceWindows()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
 		return
 	}
@@ -104,6 +97,114 @@ func JsonBackupHandler(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// getBackupConfig returns everything except checks.
+func getBackupConfig(db MonDB) (buData BackupData, err error) {
+	buData.Hosts, err = db.GetHostsList()
+	if err != nil {
+		return buData, err
+	}
+	buData.Notifications, err = db.GetHostStateChangeParamsList()
+	if err != nil {
+		return buData, err
+	}
+	buData.Settings, err = db.GetHostSettingsList()
+	if err != nil {
+		return buData, err
+	}
+	buData.Meta, err = db.GetHostMetaList()
+	if err != nil {
+		return buData, err
+	}
+	buData.Paused, err = db.GetPausedHosts()
+	if err != nil {
+		return buData, err
+	}
+	buData.Maintenance, err = db.GetMaintenanceWindows()
+	if err != nil {
+		return buData, err
+	}
+	buData.PushMonitors, err = db.GetPushMonitorsList()
+	if err != nil {
+		return buData, err
+	}
+	buData.HTTPAssertions, err = db.GetHostHTTPAssertionsList()
+	if err != nil {
+		return buData, err
+	}
+	return buData, nil
+}
+
+// restoreHost adds a host from a backup. Hosts that already exist are
+// skipped.
+func restoreHost(db MonDB, host string) error {
+	if !isValidCheckHost(host) {
+		return errors.New("Host not acceptable: " + host)
+	}
+	err := db.CheckHostExists(host)
+	if err == nil {
+		return nil
+	}
+	if err != ErrNoHostInDB {
+		return err
+	}
+	return db.AddHost(host)
+}
+
+// restoreBackupConfig restores everything except checks. Push monitors are
+// restored with their tokens, so heartbeat URLs stay valid. The caller
+// should reload the schedule and maintenance windows if db is MonData.
+func restoreBackupConfig(db MonDB, buData BackupData) (err error) {
+	for _, h := range buData.Hosts {
+		err = restoreHost(db, h)
+		if err != nil {
+			return err
+		}
+	}
+	for _, n := range buData.Notifications {
+		err = db.AddHostStateChangeParams(n)
+		if err != nil {
+			return err
+		}
+	}
+	for _, hs := range buData.Settings {
+		err = db.SetHostSettings(hs)
+		if err != nil {
+			return err
+		}
+	}
+	for _, hm := range buData.Meta {
+		err = db.SetHostMeta(hm)
+		if err != nil {
+			return err
+		}
+	}
+	for _, hp := range buData.Paused {
+		err = db.PauseHost(hp)
+		if err != nil {
+			return err
+		}
+	}
+	for _, pm := range buData.PushMonitors {
+		err = db.SetPushMonitor(pm)
+		if err != nil {
+			return err
+		}
+	}
+	for _, m := range buData.Maintenance {
+		err = db.AddMaintenanceWindow(m)
+		if err != nil {
+			return err
+		}
+	}
+	for _, a := range buData.HTTPAssertions {
+		err = db.AddHostHTTPAssertions(a)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 const JsonBackupFullHandlerEndpoint string = "/api/backup_full"
 
 func JsonBackupFullHandler(w http.ResponseWriter, r *http.Request) {
@@ -124,82 +225,55 @@ func JsonBackupFullHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var err error
-	var buData BackupData
-
 	if r.Method == http.MethodGet {
-		buData.Hosts, err = MonData.GetHostsList()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+		compress := r.URL.Query().Get("gzip")
+		if compress == "true" || compress == "1" {
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Header().Set("Content-Disposition", `attachment; filename="backup_full.ndjson.gz"`)
+		} else {
+			compress = ""
+			w.Header().Set("Content-Type", "application/x-ndjson")
 		}
-		buData.Notifications, err = MonData.GetHostStateChangeParamsList()
+		//Headers are already sent when an error occurs, so it can only be
+		//logged. The backup will have no end record and will not restore.
+		stats, err := writeBackupStream(MonData, w, compress != "")
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			log.Printf("[ERROR] backup: %v", err)
 			return
 		}
-		buData.Checks = make(map[string][]ChecksData)
-		for _, h := range buData.Hosts {
-			buData.Checks[h], err = MonData.GetChecksData(ChecksRequest{Host: h, Start: minTime, End: maxTime})
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-		}
+		log.Printf("Backup finished: %d hosts, %d checks", stats.Hosts, stats.Checks)
+		return
+	}
 
-		var jsonData []byte
-		jsonData, err = json.Marshal(buData)
+	if r.Method == http.MethodPost || r.Method == http.MethodPut {
+		stats, err := readBackupStream(MonData, r.Body, logBackupProgress)
+		reloadSchedule()
+		reloadPushTokens()
 		if err != nil {
+			log.Printf("[ERROR] restore: %v", err)
+			if errors.Is(err, ErrBadBackup) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-
-		w.Header().Set("Content-Type", "application/json")
-		_, err = w.Write(jsonData)
+		err = loadMaintenanceWindows()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		return
-	}
+		log.Printf("Restore finished: %d hosts, %d checks", stats.Hosts, stats.Checks)
 
-	if r.Method == http.MethodPost || r.Method == http.MethodPut {
-		var body []byte
-		body, err = ioutil.ReadAll(r.Body)
+		jsonData, err := json.Marshal(stats)
 		if err != nil {
-			http.Error(w, "Bad request", http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-
-		err = json.Unmarshal(body, &buData)
+		w.Header().Set("Content-Type", "application/json")
+		_, err = w.Write(jsonData)
 		if err != nil {
-			http.Error(w, "Bad request", http.StatusBadRequest)
-			return
-		}
-		for _, h := range buData.Hosts {
-			err = AddHost(h)
-			if err != nil && err != ErrHostInDB {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-		}
-		for _, n := range buData.Notifications {
-			err = MonData.AddHostStateChangeParams(n.Host, n.ChangeThreshold, n.Action)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-		}
-		if buData.Checks != nil {
-			for k, v := range buData.Checks {
-				for _, c := range v {
-					err = MonData.SaveCheck(k, c.Timestamp.UTC(), c.Rtt, c.Up)
-					if err != nil {
-						http.Error(w, err.Error(), http.StatusInternalServerError)
-						return
-					}
-				}
-			}
+			log.Printf("[ERROR] %v", err)
 		}
 		return
 	}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+// Full backups are streamed as newline delimited JSON, one record per line:
+//
+//	{"type":"header","version":1,"created":"..."}
+//	{"type":"config","config":{...}}
+//	{"type":"checks","host":"...","checks":[...]}
+//	...
+//	{"type":"end","stats":{"hosts":2,"checks":1234}}
+//
+// Config is the same as in /api/backup. Checks of a host are read in windows
+// of backupChunkDuration and split into records of at most
+// checkWriteBatchSize checks, so neither backup nor restore needs to hold
+// all checks in memory. The end record is used to detect truncated backups.
+
+const backupStreamVersion = 1
+const backupChunkDuration = 24 * time.Hour
+const backupProgressInterval = 10 * time.Second
+
+var ErrBadBackup = errors.New("bad backup")
+
+type BackupStats struct {
+	Hosts      int64            `json:"hosts"`
+	Checks     int64            `json:"checks"`
+	HostChecks map[string]int64 `json:"-"`
+}
+
+type backupRecord struct {
+	Type    string       `json:"type"`
+	Version int          `json:"version,omitempty"`
+	Created *time.Time   `json:"created,omitempty"`
+	Config  *BackupData  `json:"config,omitempty"`
+	Host    string       `json:"host,omitempty"`
+	Checks  []ChecksData `json:"checks,omitempty"`
+	Stats   *BackupStats `json:"stats,omitempty"`
+}
+
+// writeBackupStream writes a full backup of db to w.
+func writeBackupStream(db MonDB, w io.Writer, compress bool) (stats BackupStats, err error) {
+	out := w
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(w)
+		out = gz
+	}
+	enc := json.NewEncoder(out)
+
+	created := time.Now().UTC()
+	err = enc.Encode(backupRecord{Type: "header", Version: backupStreamVersion, Created: &created})
+	if err != nil {
+		return stats, err
+	}
+	buData, err := getBackupConfig(db)
+	if err != nil {
+		return stats, err
+	}
+	err = enc.Encode(backupRecord{Type: "config", Config: &buData})
+	if err != nil {
+		return stats, err
+	}
+	stats.Hosts = int64(len(buData.Hosts))
+	stats.HostChecks = make(map[string]int64)
+	for _, h := range buData.Hosts {
+		host := h
+		var n int64
+		n, err = walkHostChecks(db, host, func(cData []ChecksData) error {
+			return enc.Encode(backupRecord{Type: "checks", Host: host, Checks: cData})
+		})
+		if err != nil {
+			return stats, err
+		}
+		stats.Checks += n
+		stats.HostChecks[host] = n
+	}
+	err = enc.Encode(backupRecord{Type: "end", Stats: &stats})
+	if err != nil {
+		return stats, err
+	}
+	if gz != nil {
+		err = gz.Close()
+	}
+	return stats, err
+}
+
+// walkHostChecks reads checks of host from the first to the last one and
+// calls fn with at most checkWriteBatchSize checks at a time.
+func walkHostChecks(db MonDB, host string, fn func(cData []ChecksData) error) (n int64, err error) {
+	first, err := db.GetFirstCheckData(host)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if first.Timestamp.IsZero() {
+		return 0, nil
+	}
+	last, err := db.GetLastCheckData(host)
+	if err != nil {
+		return 0, err
+	}
+	for s := first.Timestamp.UTC().Truncate(backupChunkDuration); !s.After(last.Timestamp); s = s.Add(backupChunkDuration) {
+		var cData []ChecksData
+		cData, err = db.GetChecksData(ChecksRequest{Host: host, Start: s, End: s.Add(backupChunkDuration - time.Nanosecond)})
+		if err != nil {
+			return n, err
+		}
+		for len(cData) > 0 {
+			k := len(cData)
+			if k > checkWriteBatchSize {
+				k = checkWriteBatchSize
+			}
+			err = fn(cData[:k])
+			if err != nil {
+				return n, err
+			}
+			n += int64(k)
+			cData = cData[k:]
+		}
+	}
+	return n, nil
+}
+
+// badBackup marks errors caused by the backup contents.
+func badBackup(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || err == io.ErrUnexpectedEOF || err == gzip.ErrHeader || err == gzip.ErrChecksum {
+		return fmt.Errorf("%w: %v", ErrBadBackup, err)
+	}
+	return err
+}
+
+// readBackupStream restores a full backup from r. Gzip compressed backups
+// and backups in the old single JSON object format are also accepted.
+// Checks are written in batches and progress is called after a batch at
+// most once per backupProgressInterval. If the backup is broken the data
+// read before the error stays in db. If db is nil the backup is only read
+// and counted.
+func readBackupStream(db MonDB, r io.Reader, progress func(BackupStats)) (stats BackupStats, err error) {
+	br := bufio.NewReader(r)
+	var in io.Reader = br
+	magic, _ := br.Peek(2)
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(br)
+		if err != nil {
+			return stats, badBackup(err)
+		}
+		defer gz.Close()
+		in = gz
+	}
+	dec := json.NewDecoder(in)
+
+	var raw json.RawMessage
+	err = dec.Decode(&raw)
+	if err != nil {
+		if err == io.EOF {
+			return stats, fmt.Errorf("%w: empty backup", ErrBadBackup)
+		}
+		return stats, badBackup(err)
+	}
+	var rec backupRecord
+	err = json.Unmarshal(raw, &struct {
+		Type *string `json:"type"`
+	}{&rec.Type})
+	if err != nil {
+		return stats, badBackup(err)
+	}
+	if rec.Type == "" {
+		return readLegacyBackup(db, raw)
+	}
+	err = json.Unmarshal(raw, &rec)
+	if err != nil {
+		return stats, badBackup(err)
+	}
+	if rec.Type != "header" || rec.Version < 1 || rec.Version > backupStreamVersion {
+		return stats, fmt.Errorf("%w: unsupported format", ErrBadBackup)
+	}
+
+	var hosts map[string]bool
+	stats.HostChecks = make(map[string]int64)
+	batch := make([]HostCheck, 0, checkWriteBatchSize)
+	lastReport := time.Now()
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if db != nil {
+			e := db.SaveChecks(batch)
+			if e != nil {
+				return e
+			}
+		}
+		for _, c := range batch {
+			stats.HostChecks[c.Host]++
+		}
+		stats.Checks += int64(len(batch))
+		batch = batch[:0]
+		if progress != nil && time.Since(lastReport) >= backupProgressInterval {
+			progress(stats)
+			lastReport = time.Now()
+		}
+		return nil
+	}
+
+	for {
+		rec = backupRecord{}
+		err = dec.Decode(&rec)
+		if err != nil {
+			if err == io.EOF {
+				err = flush()
+				if err != nil {
+					return stats, err
+				}
+				return stats, fmt.Errorf("%w: backup is truncated", ErrBadBackup)
+			}
+			return stats, badBackup(err)
+		}
+		switch rec.Type {
+		case "config":
+			if rec.Config == nil || hosts != nil {
+				return stats, fmt.Errorf("%w: bad config record", ErrBadBackup)
+			}
+			if db != nil {
+				err = restoreBackupConfig(db, *rec.Config)
+				if err != nil {
+					return stats, err
+				}
+			}
+			hosts = make(map[string]bool)
+			for _, h := range rec.Config.Hosts {
+				hosts[h] = true
+				stats.HostChecks[h] = 0
+			}
+			stats.Hosts = int64(len(hosts))
+		case "checks":
+			if !hosts[rec.Host] {
+				return stats, fmt.Errorf("%w: checks of unknown host %v", ErrBadBackup, rec.Host)
+			}
+			for _, c := range rec.Checks {
+				c.Timestamp = c.Timestamp.UTC()
+				batch = append(batch, HostCheck{Host: rec.Host, ChecksData: c})
+				if len(batch) >= checkWriteBatchSize {
+					err = flush()
+					if err != nil {
+						return stats, err
+					}
+				}
+			}
+		case "end":
+			err = flush()
+			if err != nil {
+				return stats, err
+			}
+			if rec.Stats == nil || rec.Stats.Checks != stats.Checks {
+				return stats, fmt.Errorf("%w: checks count does not match", ErrBadBackup)
+			}
+			return stats, nil
+		default:
+			return stats, fmt.Errorf("%w: unknown record type %v", ErrBadBackup, rec.Type)
+		}
+	}
+}
+
+// readLegacyBackup restores a backup made before streaming backups were
+// added. It is held in memory as before.
+func readLegacyBackup(db MonDB, raw json.RawMessage) (stats BackupStats, err error) {
+	var buData BackupData
+	err = json.Unmarshal(raw, &buData)
+	if err != nil {
+		return stats, badBackup(err)
+	}
+	if db != nil {
+		err = restoreBackupConfig(db, buData)
+		if err != nil {
+			return stats, err
+		}
+	}
+	stats.Hosts = int64(len(buData.Hosts))
+	stats.HostChecks = make(map[string]int64)
+	for _, h := range buData.Hosts {
+		stats.HostChecks[h] = 0
+	}
+	for k, v := range buData.Checks {
+		batch := make([]HostCheck, 0, checkWriteBatchSize)
+		for i, c := range v {
+			c.Timestamp = c.Timestamp.UTC()
+			batch = append(batch, HostCheck{Host: k, ChecksData: c})
+			if len(batch) < checkWriteBatchSize && i < len(v)-1 {
+				continue
+			}
+			if db != nil {
+				err = db.SaveChecks(batch)
+				if err != nil {
+					return stats, err
+				}
+			}
+			stats.Checks += int64(len(batch))
+			stats.HostChecks[k] += int64(len(batch))
+			batch = batch[:0]
+		}
+	}
+	return stats, nil
+}
+
+func logBackupProgress(stats BackupStats) {
+	log.Printf("Restore in progress: %d hosts, %d checks", stats.Hosts, stats.Checks)
+}
@@ -13,6 +13,7 @@ const (
 	chartStateDown    ChartState = 1
 	chartStateUnknown ChartState = 2
 	chartStateFirst   ChartState = 3
+	chartStateMaint   ChartState = 4
 )
 
 type ChartData struct {
@@ -21,8 +22,7 @@ type ChartData struct {
 	Up        ChartState
 }
 
-func getChart(width int64, height int64, maxRtt int64, chkReq ChecksRequest, dataM *map[time.Time]ChecksData) string {
-	dt := time.Duration(Config.Checks.Interval) * time.Second
+func getChart(width int64, height int64, maxRtt int64, chkReq ChecksRequest, dt time.Duration, dataM *map[time.Time]ChecksData) string {
 	if chkReq.Start.Truncate(dt).Equal(chkReq.End.Truncate(dt)) || chkReq.Start.Truncate(dt).Add(dt).After(chkReq.End.Truncate(dt)) {
 		//TODO: error
 		return ""
@@ -84,6 +84,11 @@ rect.na {
   stroke: none;
   fill: rgba(102,102,102,1.0);
 }
+rect.mnt {
+  stroke-width: 0;
+  stroke: none;
+  fill: rgba(255,204,102,1.0);
+}
 rect.i {
   stroke-width: 0;
   stroke: none;
@@ -164,12 +169,13 @@ rect.i {
 		chart.WriteString("</text>\n")
 	}
 	//check results
-	stepIx := float64(width-xOffset) / (chkReq.End.Truncate(dt).Sub(chkReq.Start.Truncate(dt)).Seconds() / float64(Config.Checks.Interval))
+	stepIx := float64(width-xOffset) / (chkReq.End.Truncate(dt).Sub(chkReq.Start.Truncate(dt)).Seconds() / dt.Seconds())
 	stepIy := float64(yOffsetBottom-yOffsetTop) / float64(scaleTimeout.Nanoseconds())
 	var i int64 = 0
 	var statUp int64 = 0
 	var statDown int64 = 0
 	var statNa int64 = 0
+	var statMaint int64 = 0
 	var maxHeight = float64(yOffsetBottom - yOffsetTop)
 	prevState := chartStateFirst
 	var prevStateCount int64 = 0
@@ -179,7 +185,10 @@ rect.i {
 		var curState ChartState
 		d, ok := (*dataM)[t.UTC()]
 		if ok {
-			if d.Up {
+			if d.Maintenance {
+				curState = chartStateMaint
+				statMaint++
+			} else if d.Up {
 				curState = chartStateUp
 				chartRtt.WriteString("<rect x=\"")
 				chartRtt.WriteString(strconv.FormatFloat(float64(xOffset)+stepIx*float64(i), 'f', -1, 64))
@@ -236,6 +245,16 @@ rect.i {
 				chart.WriteString("\" height=\"")
 				chart.WriteString(strconv.FormatInt(yOffsetBottom-yOffsetTop, 10))
 				chart.WriteString("\" class=\"na\"/>\n")
+			case chartStateMaint:
+				chart.WriteString("<rect x=\"")
+				chart.WriteString(strconv.FormatFloat(float64(xOffset)+stepIx*float64(i-prevStateCount), 'f', -1, 64))
+				chart.WriteString("\" y=\"")
+				chart.WriteString(strconv.FormatInt(yOffsetTop, 10))
+				chart.WriteString("\" width=\"")
+				chart.WriteString(strconv.FormatFloat(stepIx*float64(prevStateCount), 'f', -1, 64))
+				chart.WriteString("\" height=\"")
+				chart.WriteString(strconv.FormatInt(yOffsetBottom-yOffsetTop, 10))
+				chart.WriteString("\" class=\"mnt\"/>\n")
 			}
 			prevState = curState
 			prevStateCount = 1
@@ -279,6 +298,16 @@ rect.i {
 		chart.WriteString("\" height=\"")
 		chart.WriteString(strconv.FormatInt(yOffsetBottom-yOffsetTop, 10))
 		chart.WriteString("\" class=\"na\"/>\n")
+	case chartStateMaint:
+		chart.WriteString("<rect x=\"")
+		chart.WriteString(strconv.FormatFloat(float64(xOffset)+stepIx*float64(i-prevStateCount), 'f', -1, 64))
+		chart.WriteString("\" y=\"")
+		chart.WriteString(strconv.FormatInt(yOffsetTop, 10))
+		chart.WriteString("\" width=\"")
+		chart.WriteString(strconv.FormatFloat(stepIx*float64(prevStateCount), 'f', -1, 64))
+		chart.WriteString("\" height=\"")
+		chart.WriteString(strconv.FormatInt(yOffsetBottom-yOffsetTop, 10))
+		chart.WriteString("\" class=\"mnt\"/>\n")
 	}
 	chart.WriteString(chartRtt.String())
 
@@ -296,6 +325,10 @@ rect.i {
 	chart.WriteString(strconv.FormatFloat(float64(100*statDown)/float64(i), 'f', 2, 64))
 	chart.WriteString("% Unknown ")
 	chart.WriteString(strconv.FormatFloat(float64(100*statNa)/float64(i), 'f', 2, 64))
+	if statMaint > 0 {
+		chart.WriteString("% Maintenance ")
+		chart.WriteString(strconv.FormatFloat(float64(100*statMaint)/float64(i), 'f', 2, 64))
+	}
 	chart.WriteString("% Average RTT ")
 	chart.WriteString(time.Duration(avgRtt).String())
 	chart.WriteString("</text>\n")
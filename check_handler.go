@@ -5,7 +5,6 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
-	"time"
 )
 
 func GetCheckRequest(w http.ResponseWriter, r *http.Request) (chkHost string, err error) {
@@ -34,7 +33,7 @@ func GetCheckRequest(w http.ResponseWriter, r *http.Request) (chkHost string, er
 		return
 	}
 
-	if getCheckType(chkHost) == checkInvalid {
+	if !isValidCheckHost(chkHost) {
 		err = errors.New("Host not acceptable")
 		http.Error(w, "Host not acceptable", http.StatusBadRequest)
 		return
@@ -48,21 +47,17 @@ const JsonCheckHandlerEndpoint string = "/api/check"
 func JsonCheckHandler(w http.ResponseWriter, r *http.Request) {
 	var err error
 	var chkHost string
-	var rtt time.Duration
 	chkHost, err = GetCheckRequest(w, r)
 	if err != nil {
 		return
 	}
 
 	var cData ChecksData
-	cData.Timestamp = time.Now().UTC()
-
-	cData.Up, rtt, err = doSingleCheck(chkHost)
+	cData, err = doSingleCheck(chkHost)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	cData.Rtt = rtt.Nanoseconds()
 
 	var jsonData []byte
 	jsonData, err = json.Marshal(cData)
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// HostCheck is a check result of a host as written by SaveChecks.
+type HostCheck struct {
+	Host string
+	ChecksData
+}
+
+// Check results are not written one by one. They are collected in a
+// buffer and written in a single transaction when the buffer is full or
+// every checkWriteFlushInterval, so all results of a tick end up in one
+// write.
+const checkWriteBatchSize = 1000
+const checkWriteFlushInterval = time.Second
+
+// checkWrite is a check result or, if flushed is set, a request to write
+// all results queued before it. flushed is closed when they are written.
+type checkWrite struct {
+	HostCheck
+	flushed chan struct{}
+}
+
+var checkWrites = make(chan checkWrite, checkWriteBatchSize)
+var checkWriterDone = make(chan struct{})
+var checkWritesClosed bool
+var checkWritesMux sync.RWMutex
+
+// queueCheckSave adds a check result to the write buffer. Results of checks
+// that finish after the writer is stopped are saved directly. The result is
+// written up to checkWriteFlushInterval later, so until then it is not
+// returned by MonDB reads. Code that reads recent checks from the DB should
+// call flushCheckWrites first.
+func queueCheckSave(host string, cData ChecksData) {
+	checkWritesMux.RLock()
+	defer checkWritesMux.RUnlock()
+	if checkWritesClosed {
+		err := MonData.SaveCheck(host, cData)
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+		}
+		return
+	}
+	checkWrites <- checkWrite{HostCheck: HostCheck{Host: host, ChecksData: cData}}
+}
+
+// flushCheckWrites writes results queued before the call and waits until
+// they are written.
+func flushCheckWrites() {
+	checkWritesMux.RLock()
+	if checkWritesClosed {
+		checkWritesMux.RUnlock()
+		return
+	}
+	flushed := make(chan struct{})
+	checkWrites <- checkWrite{flushed: flushed}
+	checkWritesMux.RUnlock()
+	<-flushed
+}
+
+func flushChecks(buf []HostCheck) {
+	if len(buf) == 0 {
+		return
+	}
+	err := MonData.SaveChecks(buf)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+}
+
+func checkWriter() {
+	defer close(checkWriterDone)
+	buf := make([]HostCheck, 0, checkWriteBatchSize)
+	ticker := time.NewTicker(checkWriteFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case c, ok := <-checkWrites:
+			if !ok {
+				flushChecks(buf)
+				return
+			}
+			if c.flushed != nil {
+				flushChecks(buf)
+				buf = buf[:0]
+				close(c.flushed)
+				continue
+			}
+			buf = append(buf, c.HostCheck)
+			if len(buf) >= checkWriteBatchSize {
+				flushChecks(buf)
+				buf = buf[:0]
+			}
+		case <-ticker.C:
+			flushChecks(buf)
+			buf = buf[:0]
+		}
+	}
+}
+
+// stopCheckWriter writes buffered results and stops the writer.
+func stopCheckWriter() {
+	checkWritesMux.Lock()
+	if checkWritesClosed {
+		checkWritesMux.Unlock()
+		return
+	}
+	checkWritesClosed = true
+	close(checkWrites)
+	checkWritesMux.Unlock()
+	<-checkWriterDone
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Checker is implemented by every probe type. Host is the full host string
+// as it was added for monitoring, including the scheme if one was given.
+// Settings always have global defaults applied. Down checks should have
+// Reason set. Returned error also marks the check as down and is used as
+// the reason.
+type Checker interface {
+	Validate(host string) bool
+	Check(host string, s HostSettings) (res ChecksData, err error)
+}
+
+var ErrUnknownCheckType = errors.New("unknown check type")
+
+// ErrNoCheckResult is returned by checkers that have nothing to report yet
+// (for example push monitors that are not overdue). Nothing is saved.
+var ErrNoCheckResult = errors.New("no check result")
+
+var checkers = make(map[string]Checker)
+var checkersMux sync.RWMutex
+
+// RegisterChecker makes a checker available for hosts added as scheme://...
+func RegisterChecker(scheme string, c Checker) {
+	checkersMux.Lock()
+	checkers[strings.ToLower(scheme)] = c
+	checkersMux.Unlock()
+}
+
+// getCheckScheme returns the scheme of a host string. Hosts added without
+// a scheme keep the old behaviour: host:port is a TCP check and anything
+// else is an ICMP check.
+func getCheckScheme(host string) string {
+	if i := strings.Index(host, "://"); i > 0 {
+		return strings.ToLower(host[:i])
+	}
+	if strings.HasPrefix(host, "[") || strings.Count(host, ":") == 1 {
+		return "tcp"
+	}
+	return "icmp"
+}
+
+// stripCheckScheme returns the host string without the scheme:// prefix.
+func stripCheckScheme(host string) string {
+	if i := strings.Index(host, "://"); i > 0 {
+		return host[i+3:]
+	}
+	return host
+}
+
+// getChecker returns the checker for a host or nil if the host is not valid
+// for any registered check type.
+func getChecker(host string) Checker {
+	if len(host) == 0 {
+		return nil
+	}
+	checkersMux.RLock()
+	c, ok := checkers[getCheckScheme(host)]
+	checkersMux.RUnlock()
+	if !ok || !c.Validate(host) {
+		return nil
+	}
+	return c
+}
+
+func isValidCheckHost(host string) bool {
+	return getChecker(host) != nil
+}
+
+// runCheck performs a check of host and folds check errors into the
+// result reason.
+func runCheck(host string, s HostSettings) (cData ChecksData, err error) {
+	checker := getChecker(host)
+	if checker == nil {
+		return cData, ErrUnknownCheckType
+	}
+
+	cData, err = checker.Check(host, s)
+	if err == ErrNoCheckResult {
+		return cData, err
+	}
+	if err != nil {
+		cData.Up = false
+		cData.Reason = err.Error()
+	}
+	if cData.Up {
+		cData.Reason = ""
+	}
+	return cData, nil
+}
+
+func isUnreachableErrno(errno syscall.Errno) bool {
+	return errno == syscall.ECONNABORTED || errno == syscall.ECONNRESET || errno == syscall.ECONNREFUSED || errno == syscall.ENETUNREACH || errno == syscall.EHOSTUNREACH || errno == syscall.EHOSTDOWN ||
+		errno == syscall.Errno(10053) || errno == syscall.Errno(10054) || errno == syscall.Errno(10061) || errno == syscall.Errno(10060)
+}
+
+// isUnreachableError reports whether err means that the host is down
+// (timeout, refused or reset connection, unreachable network or host)
+// rather than a failure of the check itself.
+func isUnreachableError(err error) bool {
+	switch err := err.(type) {
+	case *net.OpError:
+		if err.Timeout() {
+			return true
+		}
+		if sysErr, ok := err.Err.(*os.SyscallError); ok {
+			if errno, ok := sysErr.Err.(syscall.Errno); ok {
+				return isUnreachableErrno(errno)
+			}
+		}
+	case *url.Error:
+		if err, ok := err.Err.(net.Error); ok && err.Timeout() {
+			return true
+		}
+		if opErr, ok := err.Err.(*net.OpError); ok {
+			if sysErr, ok := opErr.Err.(*os.SyscallError); ok {
+				if errno, ok := sysErr.Err.(syscall.Errno); ok {
+					return isUnreachableErrno(errno)
+				}
+			}
+		}
+	case net.Error:
+		if err.Timeout() {
+			return true
+		}
+	}
+	return false
+}
+
+// addrIP returns the IP address of a network address.
+func addrIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP.String()
+	case *net.UDPAddr:
+		return a.IP.String()
+	case *net.IPAddr:
+		return a.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -10,21 +10,28 @@ import (
 )
 
 type ChecksData struct {
-	Timestamp time.Time `json:"time"`
-	Rtt       int64     `json:"rtt"`
-	Up        bool      `json:"up"`
+	Timestamp   time.Time `json:"time"`
+	Rtt         int64     `json:"rtt"`
+	Up          bool      `json:"up"`
+	Reason      string    `json:"reason,omitempty"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	IP          string    `json:"ip,omitempty"`
+	Maintenance bool      `json:"maintenance,omitempty"`
 }
 
 type ChecksRequest struct {
 	Host  string    `json:"host"`
 	Start time.Time `json:"start"`
 	End   time.Time `json:"end"`
+	//Resolution is raw (default), hour or day
+	Resolution string `json:"resolution,omitempty"`
 }
 
 func GetChecksRequest(w http.ResponseWriter, r *http.Request) (chkReq ChecksRequest, err error) {
 	switch r.Method {
 	case http.MethodGet:
 		chkReq.Host = r.URL.Query().Get("host")
+		chkReq.Resolution = r.URL.Query().Get("resolution")
 		startT := r.URL.Query().Get("start")
 		endT := r.URL.Query().Get("end")
 
@@ -87,7 +94,13 @@ func GetChecksRequest(w http.ResponseWriter, r *http.Request) (chkReq ChecksRequ
 		return
 	}
 
-	if getCheckType(chkReq.Host) == checkInvalid {
+	if !isValidResolution(chkReq.Resolution) {
+		err = errors.New("Bad resolution")
+		http.Error(w, "Bad resolution", http.StatusBadRequest)
+		return
+	}
+
+	if !isValidCheckHost(chkReq.Host) {
 		err = errors.New("Host not acceptable")
 		http.Error(w, "Host not acceptable", http.StatusBadRequest)
 		return
@@ -111,15 +124,24 @@ func JsonChecksHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var cData []ChecksData
-	cData, err = MonData.GetChecksData(chkReq)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	var jsonData []byte
-	jsonData, err = json.Marshal(cData)
+	if isRollupResolution(chkReq.Resolution) {
+		var rData []RollupData
+		rData, err = MonData.GetRollups(chkReq)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		jsonData, err = json.Marshal(rData)
+	} else {
+		var cData []ChecksData
+		cData, err = MonData.GetChecksData(chkReq)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		jsonData, err = json.Marshal(cData)
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -24,6 +24,8 @@ func GetRemoteChecks(targetUrl string, checksReq ChecksRequest) (cData []ChecksD
 		CheckRedirect: RedirectPolicyFunc,
 	}
 
+	//Remote rollups are not merged
+	checksReq.Resolution = ""
 	var reqData []byte
 	reqData, err = json.Marshal(checksReq)
 	if err != nil {
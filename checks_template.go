@@ -24,6 +24,9 @@ const checksTemplateDoc string = `<!DOCTYPE html>
 	<td>{{.Timestamp}}</td>
 	<td>{{.Rtt}}</td>
     <td>{{.Up}}</td>
+    <td>{{.Reason}}</td>
+    <td>{{if .StatusCode}}{{.StatusCode}}{{end}}</td>
+    <td>{{.IP}}</td>
   </tr>
 {{end}}
 </table>
@@ -32,6 +35,11 @@ const checksTemplateDoc string = `<!DOCTYPE html>
 </html>
 `
 
+func checksDataIn(d ChecksData, loc *time.Location) ChecksData {
+	d.Timestamp = d.Timestamp.In(loc)
+	return d
+}
+
 var checksTemplate = template.Must(template.New("Checks Template").Parse(checksTemplateDoc))
 
 const ChecksTemplateHandlerEndpoint string = "/web/checks"
@@ -54,7 +62,7 @@ func ChecksTemplateHandler(w http.ResponseWriter, r *http.Request) {
 	//RemoteChecks
 	dataM := make(map[time.Time]ChecksData)
 	for _, d := range data {
-		dataM[d.Timestamp.UTC()] = ChecksData{Timestamp: d.Timestamp.In(ChecksTZ), Rtt: d.Rtt, Up: d.Up}
+		dataM[d.Timestamp.UTC()] = checksDataIn(d, ChecksTZ)
 	}
 
 	if Config.Checks.UseRemoteChecks {
@@ -71,13 +79,13 @@ func ChecksTemplateHandler(w http.ResponseWriter, r *http.Request) {
 				if ok {
 					if dr.Up {
 						if !dl.Up {
-							dataM[dr.Timestamp] = ChecksData{Timestamp: dr.Timestamp.In(ChecksTZ), Rtt: dr.Rtt, Up: dr.Up}
+							dataM[dr.Timestamp] = checksDataIn(dr, ChecksTZ)
 						} else if dr.Rtt < dl.Rtt {
-							dataM[dr.Timestamp] = ChecksData{Timestamp: dr.Timestamp.In(ChecksTZ), Rtt: dr.Rtt, Up: dr.Up}
+							dataM[dr.Timestamp] = checksDataIn(dr, ChecksTZ)
 						}
 					}
 				} else {
-					dataM[dr.Timestamp] = ChecksData{Timestamp: dr.Timestamp.In(ChecksTZ), Rtt: dr.Rtt, Up: dr.Up}
+					dataM[dr.Timestamp] = checksDataIn(dr, ChecksTZ)
 				}
 			}
 		}
@@ -97,6 +105,20 @@ func ChecksTemplateHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Wider ranges are drawn from rollups
+const chartMaxPoints int64 = 7 * 24 * 60
+
+func chartResolution(chkReq ChecksRequest, dt time.Duration) string {
+	r := chkReq.End.Sub(chkReq.Start)
+	if int64(r/dt) <= chartMaxPoints {
+		return ResolutionRaw
+	}
+	if int64(r/time.Hour) <= chartMaxPoints {
+		return ResolutionHour
+	}
+	return ResolutionDay
+}
+
 const ChecksChartEndpoint string = "/web/checks/svg"
 
 func checksChart(w http.ResponseWriter, r *http.Request) {
@@ -107,15 +129,28 @@ func checksChart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	dt := getHostSettings(chkReq.Host).IntervalDuration()
+	if len(chkReq.Resolution) == 0 {
+		chkReq.Resolution = chartResolution(chkReq, dt)
+	}
+
 	var data []ChecksData
-	data, err = MonData.GetChecksData(chkReq)
+	if isRollupResolution(chkReq.Resolution) {
+		dt = resolutionDuration(chkReq.Resolution)
+		var rData []RollupData
+		rData, err = MonData.GetRollups(chkReq)
+		for _, r := range rData {
+			data = append(data, r.ChecksData())
+		}
+	} else {
+		data, err = MonData.GetChecksData(chkReq)
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	//RemoteChecks
-	dt := time.Duration(Config.Checks.Interval) * time.Second
 	dataM := make(map[time.Time]ChecksData)
 	var maxRtt int64 = 0
 	for _, d := range data {
@@ -125,7 +160,7 @@ func checksChart(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if Config.Checks.UseRemoteChecks {
+	if Config.Checks.UseRemoteChecks && !isRollupResolution(chkReq.Resolution) {
 		for _, CheckURL := range Config.Checks.RemoteChecksURLs {
 			var RemoteData []ChecksData
 			RemoteData, err = GetRemoteChecks(CheckURL, chkReq)
@@ -173,7 +208,7 @@ func checksChart(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	chart := getChart(1280, 720, chartMaxRtt, chkReq, &dataM)
+	chart := getChart(1280, 720, chartMaxRtt, chkReq, dt, &dataM)
 
 	w.Header().Set("Content-Type", "image/svg+xml")
 	_, err = w.Write([]byte(chart))
@@ -2,8 +2,11 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/Alexander-r/bbolt"
+	"log"
 	"time"
 )
 
@@ -17,6 +20,9 @@ func (d *MonDBBolt) Open(cfg Configuration) error {
 	if err != nil {
 		return err
 	}
+	if !migrateOnOpen {
+		return nil
+	}
 	err = d.Init()
 	return err
 }
@@ -35,9 +41,162 @@ func (d *MonDBBolt) Init() error {
 		b.FillPercent = 0.75
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	_, err = d.Migrate(false)
 	return err
 }
 
+// SchemaVersion returns the version stored in config:meta bucket. DBs
+// created before migrations were added have version 1.
+func (d *MonDBBolt) SchemaVersion() (version int64, err error) {
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		if b := tx.Bucket([]byte("config:meta")); b != nil {
+			if v := b.Get([]byte("schema_version")); len(v) == 8 {
+				version = BToI64(v)
+				return nil
+			}
+		}
+		if tx.Bucket([]byte("config:hosts")) != nil {
+			version = 1
+		}
+		return nil
+	})
+	return version, err
+}
+
+type boltMigration struct {
+	Migration
+	Apply func(tx *bbolt.Tx) error
+}
+
+// boltCreateBuckets creates the named buckets if they do not exist.
+func boltCreateBuckets(tx *bbolt.Tx, names ...string) error {
+	for _, name := range names {
+		_, e := tx.CreateBucketIfNotExists([]byte(name))
+		if e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// Check details are an optional suffix of check values, so there is no
+// migration 4 for them.
+var boltMigrations = []boltMigration{
+	{Migration{2, "Add HTTP assertions"}, func(tx *bbolt.Tx) error {
+		return boltCreateBuckets(tx, "config:http_assertions")
+	}},
+	{Migration{3, "Add host settings"}, func(tx *bbolt.Tx) error {
+		return boltCreateBuckets(tx, "config:hosts_settings")
+	}},
+	{Migration{5, "Add webhook method, headers and body to notification params"}, func(tx *bbolt.Tx) error {
+		return boltCreateBuckets(tx, "config:notifications_webhooks")
+	}},
+	{Migration{6, "Add notifications queue and delivery log"}, func(tx *bbolt.Tx) error {
+		return boltCreateBuckets(tx, "notifications:queue", "notifications:log")
+	}},
+	{Migration{7, "Add host states"}, func(tx *bbolt.Tx) error {
+		return boltCreateBuckets(tx, "state:hosts")
+	}},
+	{Migration{8, "Add incidents"}, func(tx *bbolt.Tx) error {
+		return boltCreateBuckets(tx, "state:incidents")
+	}},
+	{Migration{9, "Add check rollups"}, func(tx *bbolt.Tx) error {
+		return boltCreateBuckets(tx, "rollups:"+ResolutionHour, "rollups:"+ResolutionDay)
+	}},
+	{Migration{10, "Add host metadata"}, func(tx *bbolt.Tx) error {
+		return boltCreateBuckets(tx, "config:hosts_meta")
+	}},
+	{Migration{11, "Add maintenance windows"}, func(tx *bbolt.Tx) error {
+		return boltCreateBuckets(tx, "config:maintenance")
+	}},
+	{Migration{12, "Add host pauses"}, func(tx *bbolt.Tx) error {
+		return boltCreateBuckets(tx, "config:hosts_paused")
+	}},
+	{Migration{13, "Add push monitors"}, func(tx *bbolt.Tx) error {
+		return boltCreateBuckets(tx, "config:push_monitors")
+	}},
+	{Migration{14, "Move incidents to state:incidents bucket"}, func(tx *bbolt.Tx) error {
+		//Incidents were stored in "incidents" bucket which is also a valid
+		//host name. If such host exists its checks are left in the bucket.
+		old := tx.Bucket([]byte("incidents"))
+		if old == nil {
+			return nil
+		}
+		b, e := tx.CreateBucketIfNotExists([]byte("state:incidents"))
+		if e != nil {
+			return e
+		}
+		var keys [][]byte
+		c := old.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var i Incident
+			if json.Unmarshal(v, &i) != nil {
+				continue
+			}
+			e = b.Put(k, v)
+			if e != nil {
+				return e
+			}
+			keys = append(keys, k)
+		}
+		e = b.SetSequence(old.Sequence())
+		if e != nil {
+			return e
+		}
+		bh := tx.Bucket([]byte("config:hosts"))
+		if bh == nil || bh.Get([]byte("incidents")) == nil {
+			return tx.DeleteBucket([]byte("incidents"))
+		}
+		for _, k := range keys {
+			e = old.Delete(k)
+			if e != nil {
+				return e
+			}
+		}
+		return nil
+	}},
+}
+
+func (d *MonDBBolt) Migrate(dryRun bool) (applied []Migration, err error) {
+	applied = make([]Migration, 0)
+	version, err := d.SchemaVersion()
+	if err != nil {
+		return applied, err
+	}
+	if version == 0 {
+		return applied, ErrDBNotInitialised
+	}
+	for _, m := range boltMigrations {
+		if m.Version <= version {
+			continue
+		}
+		if dryRun {
+			applied = append(applied, m.Migration)
+			continue
+		}
+		err = d.db.Update(func(tx *bbolt.Tx) error {
+			e := m.Apply(tx)
+			if e != nil {
+				return e
+			}
+			b, e := tx.CreateBucketIfNotExists([]byte("config:meta"))
+			if e != nil {
+				return e
+			}
+			return b.Put([]byte("schema_version"), I64ToB(m.Version))
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %v", m.Version, m.Description, err)
+		}
+		log.Printf("Applied migration %d: %s", m.Version, m.Description)
+		applied = append(applied, m.Migration)
+	}
+	return applied, nil
+}
+
 func (d *MonDBBolt) GetHostsList() (hosts []string, err error) {
 	err = d.db.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte("config:hosts"))
@@ -86,6 +245,55 @@ func (d *MonDBBolt) DeleteHost(newHost string) error {
 			return e
 		}
 		e = tx.DeleteBucket([]byte(newHost))
+		for _, bn := range []string{"config:http_assertions", "config:hosts_settings", "config:hosts_meta", "config:hosts_paused", "config:push_monitors", "config:notifications_webhooks", "state:hosts"} {
+			if bc := tx.Bucket([]byte(bn)); bc != nil {
+				e = bc.Delete([]byte(newHost))
+				if e != nil {
+					return e
+				}
+			}
+		}
+		for _, resolution := range []string{ResolutionHour, ResolutionDay} {
+			br := tx.Bucket([]byte("rollups:" + resolution))
+			if br != nil && br.Bucket([]byte(newHost)) != nil {
+				e = br.DeleteBucket([]byte(newHost))
+				if e != nil {
+					return e
+				}
+			}
+		}
+		if bi := tx.Bucket([]byte("state:incidents")); bi != nil {
+			var keys [][]byte
+			c := bi.Cursor()
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				var i Incident
+				if json.Unmarshal(v, &i) == nil && i.Host == newHost {
+					keys = append(keys, k)
+				}
+			}
+			for _, k := range keys {
+				e = bi.Delete(k)
+				if e != nil {
+					return e
+				}
+			}
+		}
+		if bm := tx.Bucket([]byte("config:maintenance")); bm != nil {
+			var keys [][]byte
+			c := bm.Cursor()
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				var m MaintenanceWindow
+				if json.Unmarshal(v, &m) == nil && m.Host == newHost {
+					keys = append(keys, k)
+				}
+			}
+			for _, k := range keys {
+				e = bm.Delete(k)
+				if e != nil {
+					return e
+				}
+			}
+		}
 		return nil
 	})
 	return err
@@ -110,26 +318,93 @@ func (d *MonDBBolt) CheckHostExists(newHost string) error {
 	return err
 }
 
-func (d *MonDBBolt) SaveCheck(host string, checkTime time.Time, rtt int64, up bool) error {
+// Check values are stored as rtt (8 bytes) and flags (1 byte, bit 0 is up,
+// bit 1 is maintenance)
+// optionally followed by status code (8 bytes), ip length (1 byte), ip and
+// reason.
+func encodeBoltCheck(cData ChecksData) []byte {
+	var buf []byte = I64ToB(cData.Rtt)
+	var flags byte = 0
+	if cData.Up {
+		flags |= 1
+	}
+	if cData.Maintenance {
+		flags |= 2
+	}
+	buf = append(buf, flags)
+	if cData.StatusCode == 0 && len(cData.IP) == 0 && len(cData.Reason) == 0 {
+		return buf
+	}
+	ip := cData.IP
+	if len(ip) > 255 {
+		ip = ip[:255]
+	}
+	buf = append(buf, I64ToB(int64(cData.StatusCode))...)
+	buf = append(buf, byte(len(ip)))
+	buf = append(buf, []byte(ip)...)
+	buf = append(buf, []byte(cData.Reason)...)
+	return buf
+}
+
+func decodeBoltCheck(k []byte, v []byte) (cData ChecksData, ok bool) {
+	t := BToI64(k)
+	if t == 0 {
+		return cData, false
+	}
+	if len(v) < 9 {
+		return cData, false
+	}
+	cData.Timestamp = time.Unix(t, 0).UTC()
+	cData.Rtt = BToI64(v[:8])
+	cData.Up = v[8]&1 != 0
+	cData.Maintenance = v[8]&2 != 0
+	if len(v) < 18 {
+		return cData, true
+	}
+	cData.StatusCode = int(BToI64(v[9:17]))
+	ipLen := int(v[17])
+	if len(v) < 18+ipLen {
+		return cData, true
+	}
+	cData.IP = string(v[18 : 18+ipLen])
+	cData.Reason = string(v[18+ipLen:])
+	return cData, true
+}
+
+func (d *MonDBBolt) SaveCheck(host string, cData ChecksData) error {
 	err := d.db.Batch(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(host))
 		if b == nil {
 			return ErrNoHostInDB
 		}
 		b.FillPercent = 0.95
-		var buf []byte = I64ToB(rtt)
-		if up {
-			buf = append(buf, 1)
-		} else {
-			buf = append(buf, 0)
-		}
-		t := I64ToB(checkTime.Unix())
-		e := b.Put(t, buf)
+		t := I64ToB(cData.Timestamp.Unix())
+		e := b.Put(t, encodeBoltCheck(cData))
 		return e
 	})
 	return err
 }
 
+// SaveChecks writes all checks in one transaction. Checks of hosts that
+// were deleted in the meantime are skipped.
+func (d *MonDBBolt) SaveChecks(checks []HostCheck) error {
+	err := d.db.Batch(func(tx *bbolt.Tx) error {
+		for _, c := range checks {
+			b := tx.Bucket([]byte(c.Host))
+			if b == nil {
+				continue
+			}
+			b.FillPercent = 0.95
+			e := b.Put(I64ToB(c.Timestamp.Unix()), encodeBoltCheck(c.ChecksData))
+			if e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+	return err
+}
+
 func (d *MonDBBolt) GetChecksData(chkReq ChecksRequest) (cData []ChecksData, err error) {
 	err = d.db.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(chkReq.Host))
@@ -140,18 +415,10 @@ func (d *MonDBBolt) GetChecksData(chkReq ChecksRequest) (cData []ChecksData, err
 		tEnd := I64ToB(chkReq.End.Unix())
 		c := b.Cursor()
 		for k, v := c.Seek(tStart); k != nil && bytes.Compare(k, tEnd) <= 0; k, v = c.Next() {
-			t := BToI64(k)
-			if t == 0 {
-				continue
-			}
-			if len(v) != 9 {
+			cd, ok := decodeBoltCheck(k, v)
+			if !ok {
 				continue
 			}
-			var up bool = false
-			if v[8] != 0 {
-				up = true
-			}
-			var cd ChecksData = ChecksData{Timestamp: time.Unix(t, 0).UTC(), Rtt: BToI64(v[:8]), Up: up}
 			cData = append(cData, cd)
 		}
 		return nil
@@ -167,25 +434,44 @@ func (d *MonDBBolt) GetLastCheckData(host string) (cData ChecksData, err error)
 		}
 		c := b.Cursor()
 		k, v := c.Last()
-		t := BToI64(k)
-		if t == 0 {
-			return nil
+		cd, ok := decodeBoltCheck(k, v)
+		if ok {
+			cData = cd
 		}
-		if len(v) != 9 {
+		return nil
+	})
+	return cData, err
+}
+
+func (d *MonDBBolt) GetFirstCheckData(host string) (cData ChecksData, err error) {
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(host))
+		if b == nil {
 			return nil
 		}
-		var up bool = false
-		if v[8] != 0 {
-			up = true
+		c := b.Cursor()
+		k, v := c.First()
+		cd, ok := decodeBoltCheck(k, v)
+		if ok {
+			cData = cd
 		}
-		cData.Timestamp = time.Unix(t, 0).UTC()
-		cData.Rtt = BToI64(v[:8])
-		cData.Up = up
 		return nil
 	})
 	return cData, err
 }
 
+func (d *MonDBBolt) GetChecksStats(host string, start time.Time, end time.Time) (s ChecksStats, err error) {
+	cData, err := d.GetChecksData(ChecksRequest{Host: host, Start: start, End: end})
+	if err != nil {
+		return s, err
+	}
+	return computeChecksStats(cData), nil
+}
+
+func (d *MonDBBolt) GetMonthlyChecks(start time.Time, end time.Time) (m []MonthlyChecks, err error) {
+	return computeMonthlyChecks(d, start, end)
+}
+
 func (d *MonDBBolt) DeleteOldChecks(beforeTime time.Time) error {
 	bt := I64ToB(beforeTime.Unix())
 	err := d.db.Batch(func(tx *bbolt.Tx) error {
@@ -213,23 +499,178 @@ func (d *MonDBBolt) DeleteOldChecks(beforeTime time.Time) error {
 	return err
 }
 
-func (d *MonDBBolt) AddHostStateChangeParams(newHost string, newThreshold int64, newAction string) error {
-	var hostExists bool = false
+// Rollups are stored as JSON in a bucket per host inside rollups:hour and
+// rollups:day buckets keyed by the bucket start time.
+
+func (d *MonDBBolt) SaveRollups(host string, resolution string, r []RollupData) error {
+	err := d.db.Batch(func(tx *bbolt.Tx) error {
+		bh := tx.Bucket([]byte("config:hosts"))
+		if bh == nil {
+			return errors.New("DB not initialised")
+		}
+		if bh.Get([]byte(host)) == nil {
+			return ErrNoHostInDB
+		}
+		br := tx.Bucket([]byte("rollups:" + resolution))
+		if br == nil {
+			return errors.New("DB not initialised")
+		}
+		b, e := br.CreateBucketIfNotExists([]byte(host))
+		if e != nil {
+			return e
+		}
+		b.FillPercent = 0.95
+		for _, rd := range r {
+			buf, e := json.Marshal(rd)
+			if e != nil {
+				return e
+			}
+			e = b.Put(I64ToB(rd.Timestamp.Unix()), buf)
+			if e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+func getBoltRollupsBucket(tx *bbolt.Tx, host string, resolution string) *bbolt.Bucket {
+	br := tx.Bucket([]byte("rollups:" + resolution))
+	if br == nil {
+		return nil
+	}
+	return br.Bucket([]byte(host))
+}
+
+func (d *MonDBBolt) GetRollups(chkReq ChecksRequest) (r []RollupData, err error) {
+	r = make([]RollupData, 0)
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := getBoltRollupsBucket(tx, chkReq.Host, chkReq.Resolution)
+		if b == nil {
+			return nil
+		}
+		tStart := I64ToB(chkReq.Start.Unix())
+		tEnd := I64ToB(chkReq.End.Unix())
+		c := b.Cursor()
+		for k, v := c.Seek(tStart); k != nil && bytes.Compare(k, tEnd) <= 0; k, v = c.Next() {
+			var rd RollupData
+			e := json.Unmarshal(v, &rd)
+			if e != nil {
+				return e
+			}
+			r = append(r, rd)
+		}
+		return nil
+	})
+	return r, err
+}
+
+func (d *MonDBBolt) GetLastRollup(host string, resolution string) (r RollupData, err error) {
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := getBoltRollupsBucket(tx, host, resolution)
+		if b == nil {
+			return nil
+		}
+		_, v := b.Cursor().Last()
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &r)
+	})
+	return r, err
+}
+
+func (d *MonDBBolt) DeleteOldRollups(resolution string, beforeTime time.Time) error {
+	bt := I64ToB(beforeTime.Unix())
 	err := d.db.Batch(func(tx *bbolt.Tx) error {
+		br := tx.Bucket([]byte("rollups:" + resolution))
+		if br == nil {
+			return errors.New("DB not initialised")
+		}
+		ch := br.Cursor()
+		for h, _ := ch.First(); h != nil; h, _ = ch.Next() {
+			b := br.Bucket(h)
+			if b == nil {
+				continue
+			}
+			var keys [][]byte
+			c := b.Cursor()
+			for k, _ := c.First(); k != nil && bytes.Compare(k, bt) < 0; k, _ = c.Next() {
+				keys = append(keys, k)
+			}
+			for _, k := range keys {
+				e := b.Delete(k)
+				if e != nil {
+					return e
+				}
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// Webhook method, headers and body are stored as JSON in
+// config:notifications_webhooks bucket. Threshold and action stay in
+// config:hosts values.
+type boltWebhookParams struct {
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+func getBoltWebhookParams(tx *bbolt.Tx, host string, p *StateChangeParams) error {
+	b := tx.Bucket([]byte("config:notifications_webhooks"))
+	if b == nil {
+		return nil
+	}
+	v := b.Get([]byte(host))
+	if v == nil {
+		return nil
+	}
+	var wp boltWebhookParams
+	e := json.Unmarshal(v, &wp)
+	if e != nil {
+		return e
+	}
+	p.Method = wp.Method
+	p.Headers = wp.Headers
+	p.Body = wp.Body
+	return nil
+}
+
+func (d *MonDBBolt) AddHostStateChangeParams(p StateChangeParams) error {
+	wp, err := json.Marshal(boltWebhookParams{Method: p.Method, Headers: p.Headers, Body: p.Body})
+	if err != nil {
+		return err
+	}
+	var hostExists bool = false
+	err = d.db.Batch(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte("config:hosts"))
 		if b == nil {
 			return errors.New("DB not initialised")
 		}
 		b.FillPercent = 0.75
-		v := b.Get([]byte(newHost))
+		v := b.Get([]byte(p.Host))
 		if v == nil {
 			return nil
 		}
 		hostExists = true
-		var buf []byte = I64ToB(newThreshold)
-		buf = append(buf, []byte(newAction)...)
-		e := b.Put([]byte(newHost), buf)
-		return e
+		var buf []byte = I64ToB(p.ChangeThreshold)
+		buf = append(buf, []byte(p.Action)...)
+		e := b.Put([]byte(p.Host), buf)
+		if e != nil {
+			return e
+		}
+		bw := tx.Bucket([]byte("config:notifications_webhooks"))
+		if bw == nil {
+			return errors.New("DB not initialised")
+		}
+		if p.Method == "" && len(p.Headers) == 0 && p.Body == "" {
+			return bw.Delete([]byte(p.Host))
+		}
+		return bw.Put([]byte(p.Host), wp)
 	})
 	if hostExists == false && err == nil {
 		return ErrNoHostInDB
@@ -253,6 +694,7 @@ func (d *MonDBBolt) GetHostStateChangeParams(host string) (p StateChangeParams,
 			p.ChangeThreshold = BToI64(v[:8])
 			p.Action = string(v[8:])
 			p.Host = host
+			return getBoltWebhookParams(tx, host, &p)
 		}
 		return nil
 	})
@@ -281,6 +723,10 @@ func (d *MonDBBolt) GetHostStateChangeParamsList() (p []StateChangeParams, err e
 			s.ChangeThreshold = BToI64(v[:8])
 			s.Action = string(vc)
 			s.Host = string(kc)
+			e := getBoltWebhookParams(tx, s.Host, &s)
+			if e != nil {
+				return e
+			}
 			p = append(p, s)
 		}
 		return nil
@@ -301,7 +747,668 @@ func (d *MonDBBolt) DeleteHostStateChangeParams(newHost string) error {
 		}
 		var buf []byte = []byte{0, 0, 0, 0, 0, 0, 0, 0}
 		e := b.Put([]byte(newHost), buf)
-		return e
+		if e != nil {
+			return e
+		}
+		if bw := tx.Bucket([]byte("config:notifications_webhooks")); bw != nil {
+			return bw.Delete([]byte(newHost))
+		}
+		return nil
+	})
+	return err
+}
+
+func (d *MonDBBolt) SetHostSettings(s HostSettings) error {
+	buf, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	err = d.db.Batch(func(tx *bbolt.Tx) error {
+		bh := tx.Bucket([]byte("config:hosts"))
+		if bh == nil {
+			return errors.New("DB not initialised")
+		}
+		if bh.Get([]byte(s.Host)) == nil {
+			return ErrNoHostInDB
+		}
+		b := tx.Bucket([]byte("config:hosts_settings"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		return b.Put([]byte(s.Host), buf)
+	})
+	return err
+}
+
+func (d *MonDBBolt) GetHostSettings(host string) (s HostSettings, err error) {
+	var found bool = false
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("config:hosts_settings"))
+		if b == nil {
+			return nil
+		}
+		v := b.Get([]byte(host))
+		if v == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(v, &s)
+	})
+	if err == nil && !found {
+		return s, ErrNoHostInDB
+	}
+	return s, err
+}
+
+func (d *MonDBBolt) GetHostSettingsList() (s []HostSettings, err error) {
+	s = make([]HostSettings, 0)
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("config:hosts_settings"))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var hs HostSettings
+			e := json.Unmarshal(v, &hs)
+			if e != nil {
+				return e
+			}
+			s = append(s, hs)
+		}
+		return nil
+	})
+	return s, err
+}
+
+func (d *MonDBBolt) SetHostMeta(m HostMeta) error {
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	err = d.db.Batch(func(tx *bbolt.Tx) error {
+		bh := tx.Bucket([]byte("config:hosts"))
+		if bh == nil {
+			return errors.New("DB not initialised")
+		}
+		if bh.Get([]byte(m.Host)) == nil {
+			return ErrNoHostInDB
+		}
+		b := tx.Bucket([]byte("config:hosts_meta"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		return b.Put([]byte(m.Host), buf)
+	})
+	return err
+}
+
+func (d *MonDBBolt) GetHostMeta(host string) (m HostMeta, err error) {
+	var found bool = false
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("config:hosts_meta"))
+		if b == nil {
+			return nil
+		}
+		v := b.Get([]byte(host))
+		if v == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(v, &m)
+	})
+	if err == nil && !found {
+		return m, ErrNoHostInDB
+	}
+	return m, err
+}
+
+func (d *MonDBBolt) GetHostMetaList() (m []HostMeta, err error) {
+	m = make([]HostMeta, 0)
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("config:hosts_meta"))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var hm HostMeta
+			e := json.Unmarshal(v, &hm)
+			if e != nil {
+				return e
+			}
+			m = append(m, hm)
+		}
+		return nil
+	})
+	return m, err
+}
+
+func (d *MonDBBolt) PauseHost(p HostPause) error {
+	buf, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	err = d.db.Batch(func(tx *bbolt.Tx) error {
+		bh := tx.Bucket([]byte("config:hosts"))
+		if bh == nil {
+			return errors.New("DB not initialised")
+		}
+		if bh.Get([]byte(p.Host)) == nil {
+			return ErrNoHostInDB
+		}
+		b := tx.Bucket([]byte("config:hosts_paused"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		return b.Put([]byte(p.Host), buf)
+	})
+	return err
+}
+
+func (d *MonDBBolt) ResumeHost(host string) error {
+	err := d.db.Batch(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("config:hosts_paused"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		return b.Delete([]byte(host))
+	})
+	return err
+}
+
+func (d *MonDBBolt) GetPausedHosts() (p []HostPause, err error) {
+	p = make([]HostPause, 0)
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("config:hosts_paused"))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var hp HostPause
+			e := json.Unmarshal(v, &hp)
+			if e != nil {
+				return e
+			}
+			p = append(p, hp)
+		}
+		return nil
+	})
+	return p, err
+}
+
+func (d *MonDBBolt) SetPushMonitor(m PushMonitor) error {
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	err = d.db.Batch(func(tx *bbolt.Tx) error {
+		bh := tx.Bucket([]byte("config:hosts"))
+		if bh == nil {
+			return errors.New("DB not initialised")
+		}
+		if bh.Get([]byte(m.Host)) == nil {
+			return ErrNoHostInDB
+		}
+		b := tx.Bucket([]byte("config:push_monitors"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		return b.Put([]byte(m.Host), buf)
+	})
+	return err
+}
+
+func (d *MonDBBolt) GetPushMonitor(host string) (m PushMonitor, err error) {
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("config:push_monitors"))
+		if b == nil {
+			return ErrNoHostInDB
+		}
+		v := b.Get([]byte(host))
+		if v == nil {
+			return ErrNoHostInDB
+		}
+		return json.Unmarshal(v, &m)
+	})
+	return m, err
+}
+
+func (d *MonDBBolt) GetPushMonitorsList() (m []PushMonitor, err error) {
+	m = make([]PushMonitor, 0)
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("config:push_monitors"))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var pm PushMonitor
+			e := json.Unmarshal(v, &pm)
+			if e != nil {
+				return e
+			}
+			m = append(m, pm)
+		}
+		return nil
+	})
+	return m, err
+}
+
+func (d *MonDBBolt) AddHostHTTPAssertions(a HTTPAssertions) error {
+	buf, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	err = d.db.Batch(func(tx *bbolt.Tx) error {
+		bh := tx.Bucket([]byte("config:hosts"))
+		if bh == nil {
+			return errors.New("DB not initialised")
+		}
+		if bh.Get([]byte(a.Host)) == nil {
+			return ErrNoHostInDB
+		}
+		b := tx.Bucket([]byte("config:http_assertions"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		return b.Put([]byte(a.Host), buf)
+	})
+	return err
+}
+
+func (d *MonDBBolt) GetHostHTTPAssertions(host string) (a HTTPAssertions, err error) {
+	var found bool = false
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("config:http_assertions"))
+		if b == nil {
+			return nil
+		}
+		v := b.Get([]byte(host))
+		if v == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(v, &a)
+	})
+	if err == nil && !found {
+		return a, ErrNoHostInDB
+	}
+	return a, err
+}
+
+func (d *MonDBBolt) GetHostHTTPAssertionsList() (a []HTTPAssertions, err error) {
+	a = make([]HTTPAssertions, 0)
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("config:http_assertions"))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var s HTTPAssertions
+			e := json.Unmarshal(v, &s)
+			if e != nil {
+				return e
+			}
+			a = append(a, s)
+		}
+		return nil
+	})
+	return a, err
+}
+
+func (d *MonDBBolt) DeleteHostHTTPAssertions(host string) error {
+	err := d.db.Batch(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("config:http_assertions"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		return b.Delete([]byte(host))
+	})
+	return err
+}
+
+// Queued notifications and log entries are stored as JSON keyed by the
+// bucket sequence number.
+
+func (d *MonDBBolt) AddQueuedNotification(n QueuedNotification) error {
+	err := d.db.Batch(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("notifications:queue"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		id, e := b.NextSequence()
+		if e != nil {
+			return e
+		}
+		n.ID = int64(id)
+		buf, e := json.Marshal(n)
+		if e != nil {
+			return e
+		}
+		return b.Put(I64ToB(n.ID), buf)
+	})
+	return err
+}
+
+func (d *MonDBBolt) GetQueuedNotifications() (n []QueuedNotification, err error) {
+	n = make([]QueuedNotification, 0)
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("notifications:queue"))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var q QueuedNotification
+			e := json.Unmarshal(v, &q)
+			if e != nil {
+				return e
+			}
+			n = append(n, q)
+		}
+		return nil
+	})
+	return n, err
+}
+
+func (d *MonDBBolt) UpdateQueuedNotification(n QueuedNotification) error {
+	buf, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	err = d.db.Batch(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("notifications:queue"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		if b.Get(I64ToB(n.ID)) == nil {
+			return nil
+		}
+		return b.Put(I64ToB(n.ID), buf)
+	})
+	return err
+}
+
+func (d *MonDBBolt) DeleteQueuedNotification(id int64) error {
+	err := d.db.Batch(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("notifications:queue"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		return b.Delete(I64ToB(id))
+	})
+	return err
+}
+
+func (d *MonDBBolt) AddNotificationLog(l NotificationLogEntry) error {
+	buf, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	err = d.db.Batch(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("notifications:log"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		b.FillPercent = 0.95
+		id, e := b.NextSequence()
+		if e != nil {
+			return e
+		}
+		return b.Put(I64ToB(int64(id)), buf)
+	})
+	return err
+}
+
+func (d *MonDBBolt) GetNotificationLog(host string, limit int) (l []NotificationLogEntry, err error) {
+	l = make([]NotificationLogEntry, 0)
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("notifications:log"))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil && len(l) < limit; k, v = c.Prev() {
+			var e NotificationLogEntry
+			err := json.Unmarshal(v, &e)
+			if err != nil {
+				return err
+			}
+			if len(host) > 0 && e.Host != host {
+				continue
+			}
+			l = append(l, e)
+		}
+		return nil
+	})
+	return l, err
+}
+
+func (d *MonDBBolt) DeleteOldNotificationLog(beforeTime time.Time) error {
+	err := d.db.Batch(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("notifications:log"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		var keys [][]byte
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var l NotificationLogEntry
+			e := json.Unmarshal(v, &l)
+			if e == nil && !l.Time.Before(beforeTime) {
+				break
+			}
+			keys = append(keys, k)
+		}
+		for _, k := range keys {
+			e := b.Delete(k)
+			if e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+func (d *MonDBBolt) SetHostState(s StateChangeData) error {
+	buf, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	err = d.db.Batch(func(tx *bbolt.Tx) error {
+		bh := tx.Bucket([]byte("config:hosts"))
+		if bh == nil {
+			return errors.New("DB not initialised")
+		}
+		if bh.Get([]byte(s.Host)) == nil {
+			return ErrNoHostInDB
+		}
+		b := tx.Bucket([]byte("state:hosts"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		return b.Put([]byte(s.Host), buf)
+	})
+	return err
+}
+
+func (d *MonDBBolt) GetHostStatesList() (s []StateChangeData, err error) {
+	s = make([]StateChangeData, 0)
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("state:hosts"))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var hs StateChangeData
+			e := json.Unmarshal(v, &hs)
+			if e != nil {
+				return e
+			}
+			s = append(s, hs)
+		}
+		return nil
+	})
+	return s, err
+}
+
+// Incidents are stored as JSON keyed by the bucket sequence number.
+
+func (d *MonDBBolt) AddIncident(i Incident) error {
+	err := d.db.Batch(func(tx *bbolt.Tx) error {
+		bh := tx.Bucket([]byte("config:hosts"))
+		if bh == nil {
+			return errors.New("DB not initialised")
+		}
+		if bh.Get([]byte(i.Host)) == nil {
+			return ErrNoHostInDB
+		}
+		b := tx.Bucket([]byte("state:incidents"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		id, e := b.NextSequence()
+		if e != nil {
+			return e
+		}
+		i.ID = int64(id)
+		buf, e := json.Marshal(i)
+		if e != nil {
+			return e
+		}
+		return b.Put(I64ToB(i.ID), buf)
+	})
+	return err
+}
+
+func (d *MonDBBolt) GetOpenIncident(host string) (i Incident, err error) {
+	var found bool = false
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("state:incidents"))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var bi Incident
+			e := json.Unmarshal(v, &bi)
+			if e != nil {
+				return e
+			}
+			if bi.Host == host && bi.End == nil {
+				i = bi
+				found = true
+				return nil
+			}
+		}
+		return nil
+	})
+	if err == nil && !found {
+		return i, ErrNoIncident
+	}
+	return i, err
+}
+
+func (d *MonDBBolt) CloseIncident(i Incident) error {
+	buf, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+	err = d.db.Batch(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("state:incidents"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		if b.Get(I64ToB(i.ID)) == nil {
+			return ErrNoIncident
+		}
+		return b.Put(I64ToB(i.ID), buf)
+	})
+	return err
+}
+
+func (d *MonDBBolt) GetIncidents(host string, start time.Time, end time.Time) (i []Incident, err error) {
+	i = make([]Incident, 0)
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("state:incidents"))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var bi Incident
+			e := json.Unmarshal(v, &bi)
+			if e != nil {
+				return e
+			}
+			if len(host) > 0 && bi.Host != host {
+				continue
+			}
+			if bi.Start.After(end) || (bi.End != nil && bi.End.Before(start)) {
+				continue
+			}
+			i = append(i, bi)
+		}
+		return nil
+	})
+	return i, err
+}
+
+// Maintenance windows are stored as JSON keyed by the bucket sequence number.
+
+func (d *MonDBBolt) AddMaintenanceWindow(m MaintenanceWindow) error {
+	err := d.db.Batch(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("config:maintenance"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		id, e := b.NextSequence()
+		if e != nil {
+			return e
+		}
+		m.ID = int64(id)
+		buf, e := json.Marshal(m)
+		if e != nil {
+			return e
+		}
+		return b.Put(I64ToB(m.ID), buf)
+	})
+	return err
+}
+
+func (d *MonDBBolt) GetMaintenanceWindows() (m []MaintenanceWindow, err error) {
+	m = make([]MaintenanceWindow, 0)
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("config:maintenance"))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var bm MaintenanceWindow
+			e := json.Unmarshal(v, &bm)
+			if e != nil {
+				return e
+			}
+			m = append(m, bm)
+		}
+		return nil
+	})
+	return m, err
+}
+
+func (d *MonDBBolt) DeleteMaintenanceWindow(id int64) error {
+	err := d.db.Batch(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("config:maintenance"))
+		if b == nil {
+			return errors.New("DB not initialised")
+		}
+		return b.Delete(I64ToB(id))
 	})
 	return err
 }
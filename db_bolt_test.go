@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Alexander-r/bbolt"
+)
+
+func openTestBolt(t *testing.T) *MonDBBolt {
+	var cfg Configuration
+	cfg.DB.Database = filepath.Join(t.TempDir(), "test.db")
+	d := &MonDBBolt{}
+	err := d.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestBoltHostNamedIncidents(t *testing.T) {
+	d := openTestBolt(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	err := d.AddHost("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.AddIncident(Incident{Host: "example.com", Start: now.Add(-time.Minute), Reason: "timeout", Checks: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.AddHost("incidents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.SaveCheck("incidents", ChecksData{Timestamp: now, Rtt: 1000, Up: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cData, err := d.GetChecksData(ChecksRequest{Host: "incidents", Start: now.Add(-time.Hour), End: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cData) != 1 {
+		t.Fatalf("expected 1 check of host incidents, got %d", len(cData))
+	}
+	incidents, err := d.GetIncidents("", now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incidents) != 1 {
+		t.Fatalf("expected 1 incident, got %d", len(incidents))
+	}
+
+	err = d.DeleteHost("incidents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	incidents, err = d.GetIncidents("example.com", now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incidents) != 1 {
+		t.Fatalf("incidents of other hosts were deleted: got %d", len(incidents))
+	}
+	err = d.AddIncident(Incident{Host: "example.com", Start: now, Reason: "timeout", Checks: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBoltMigrateIncidentsBucket(t *testing.T) {
+	d := openTestBolt(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	err := d.AddHost("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.AddHost("incidents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.SaveCheck("incidents", ChecksData{Timestamp: now, Rtt: 1000, Up: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Recreate the layout of schema version 2 where incidents shared the
+	//bucket with checks of host incidents
+	err = d.db.Update(func(tx *bbolt.Tx) error {
+		e := tx.DeleteBucket([]byte("state:incidents"))
+		if e != nil {
+			return e
+		}
+		b := tx.Bucket([]byte("incidents"))
+		buf, e := json.Marshal(Incident{ID: 7, Host: "example.com", Start: now.Add(-time.Minute), Reason: "timeout", Checks: 3})
+		if e != nil {
+			return e
+		}
+		e = b.Put(I64ToB(7), buf)
+		if e != nil {
+			return e
+		}
+		e = b.SetSequence(7)
+		if e != nil {
+			return e
+		}
+		return tx.Bucket([]byte("config:meta")).Put([]byte("schema_version"), I64ToB(2))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = d.Migrate(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	incidents, err := d.GetIncidents("", now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incidents) != 1 || incidents[0].ID != 7 {
+		t.Fatalf("expected moved incident 7, got %v", incidents)
+	}
+	cData, err := d.GetChecksData(ChecksRequest{Host: "incidents", Start: now.Add(-time.Hour), End: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cData) != 1 {
+		t.Fatalf("expected 1 check of host incidents, got %d", len(cData))
+	}
+	err = d.AddIncident(Incident{Host: "example.com", Start: now, Reason: "timeout", Checks: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	incidents, err = d.GetIncidents("example.com", now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incidents) != 2 || incidents[1].ID != 8 {
+		t.Fatalf("expected new incident 8, got %v", incidents)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// -export, -import and -copy-to options work with the DB directly without
+// starting the web server. They use the full backup format, so exported
+// files can also be restored with /api/backup_full. Checks are copied in
+// chunks and the number of checks of every host is verified afterwards.
+
+// runExportMode writes a full backup to path ("-" for stdout). The backup is
+// gzip compressed if path ends with .gz.
+func runExportMode(path string) error {
+	var out io.Writer = os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	stats, err := writeBackupStream(MonData, out, strings.HasSuffix(path, ".gz"))
+	if err != nil {
+		return err
+	}
+	log.Printf("Exported %d hosts, %d checks", stats.Hosts, stats.Checks)
+	if path == "-" {
+		return nil
+	}
+
+	//Read the file back to verify it
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fileStats, err := readBackupStream(nil, f, nil)
+	if err != nil {
+		return err
+	}
+	return compareChecksCount(stats.HostChecks, fileStats.HostChecks, true)
+}
+
+// runImportMode restores a full backup from path ("-" for stdin).
+func runImportMode(path string) error {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	stats, err := readBackupStream(MonData, in, logBackupProgress)
+	if err != nil {
+		return err
+	}
+	log.Printf("Imported %d hosts, %d checks", stats.Hosts, stats.Checks)
+	return verifyChecksCount(MonData, stats.HostChecks, false)
+}
+
+// runCopyMode copies all data to the DB described by dbConfig, for example
+// {"Type":"pq","Host":"127.0.0.1","Port":"5432","User":"gosrvmon",
+// "Password":"...","Database":"gosrvmon"}. The target DB must not have any
+// hosts, it is initialised if it is empty.
+func runCopyMode(dbConfig string) error {
+	cfg := Config
+	cfg.DB.Type = ""
+	cfg.DB.Host = ""
+	cfg.DB.Port = ""
+	cfg.DB.User = ""
+	cfg.DB.Password = ""
+	cfg.DB.Database = ""
+	err := json.Unmarshal([]byte(dbConfig), &cfg.DB)
+	if err != nil {
+		return fmt.Errorf("bad target DB config: %v", err)
+	}
+	if cfg.DB.Type == "pg" {
+		cfg.DB.Type = "pq"
+	}
+	if cfg.DB.Type == "bbolt" {
+		cfg.DB.Type = "bolt"
+	}
+	if cfg.DB.Database == "" {
+		return fmt.Errorf("bad target DB config: no database")
+	}
+	dst := newMonDB(cfg.DB.Type)
+	err = dst.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	version, err := dst.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		err = dst.Init()
+		if err != nil {
+			return err
+		}
+	}
+	hosts, err := dst.GetHostsList()
+	if err != nil {
+		return err
+	}
+	if len(hosts) > 0 {
+		return fmt.Errorf("target DB is not empty: %d hosts", len(hosts))
+	}
+
+	//Backup is written and restored at the same time, so only a few chunks
+	//of checks are held in memory
+	pr, pw := io.Pipe()
+	go func() {
+		_, e := writeBackupStream(MonData, pw, false)
+		pw.CloseWithError(e)
+	}()
+	stats, err := readBackupStream(dst, pr, logBackupProgress)
+	pr.Close()
+	if err != nil {
+		return err
+	}
+	log.Printf("Copied %d hosts, %d checks", stats.Hosts, stats.Checks)
+	return verifyChecksCount(dst, stats.HostChecks, true)
+}
+
+// countHostChecks returns the number of checks of host stored in db.
+func countHostChecks(db MonDB, host string) (n int64, err error) {
+	return walkHostChecks(db, host, func(cData []ChecksData) error {
+		return nil
+	})
+}
+
+// verifyChecksCount checks that db has expected number of checks for every
+// host. Unless exact is set there can be more if db already had checks of
+// the host.
+func verifyChecksCount(db MonDB, expected map[string]int64, exact bool) error {
+	found := make(map[string]int64)
+	for h := range expected {
+		err := db.CheckHostExists(h)
+		if err != nil {
+			return fmt.Errorf("%v: %v", h, err)
+		}
+		found[h], err = countHostChecks(db, h)
+		if err != nil {
+			return err
+		}
+	}
+	return compareChecksCount(expected, found, exact)
+}
+
+func compareChecksCount(expected map[string]int64, found map[string]int64, exact bool) error {
+	hosts := make([]string, 0, len(expected))
+	for h := range expected {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	var total int64
+	for _, h := range hosts {
+		if found[h] < expected[h] || (exact && found[h] != expected[h]) {
+			return fmt.Errorf("checks count of %v does not match: %d expected, %d found", h, expected[h], found[h])
+		}
+		total += found[h]
+	}
+	log.Printf("Verified %d hosts, %d checks", len(hosts), total)
+	return nil
+}
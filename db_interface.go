@@ -9,18 +9,61 @@ type MonDB interface {
 	Open(cfg Configuration) error
 	Close() error
 	Init() error
+	SchemaVersion() (version int64, err error)
+	Migrate(dryRun bool) (applied []Migration, err error)
 	GetHostsList() (hosts []string, err error)
 	AddHost(newHost string) error
 	DeleteHost(newHost string) error
 	CheckHostExists(newHost string) error
-	SaveCheck(host string, checkTime time.Time, rtt int64, up bool) error
+	SaveCheck(host string, cData ChecksData) error
+	SaveChecks(checks []HostCheck) error
 	GetChecksData(chkReq ChecksRequest) (cData []ChecksData, err error)
 	GetLastCheckData(host string) (cData ChecksData, err error)
+	GetFirstCheckData(host string) (cData ChecksData, err error)
+	GetChecksStats(host string, start time.Time, end time.Time) (s ChecksStats, err error)
+	GetMonthlyChecks(start time.Time, end time.Time) (m []MonthlyChecks, err error)
 	DeleteOldChecks(beforeTime time.Time) error
-	AddHostStateChangeParams(newHost string, newThreshold int64, newAction string) error
+	SaveRollups(host string, resolution string, r []RollupData) error
+	GetRollups(chkReq ChecksRequest) (r []RollupData, err error)
+	GetLastRollup(host string, resolution string) (r RollupData, err error)
+	DeleteOldRollups(resolution string, beforeTime time.Time) error
+	AddHostStateChangeParams(p StateChangeParams) error
 	GetHostStateChangeParams(host string) (p StateChangeParams, err error)
 	GetHostStateChangeParamsList() (p []StateChangeParams, err error)
 	DeleteHostStateChangeParams(newHost string) error
+	SetHostSettings(s HostSettings) error
+	GetHostSettings(host string) (s HostSettings, err error)
+	GetHostSettingsList() (s []HostSettings, err error)
+	SetHostMeta(m HostMeta) error
+	GetHostMeta(host string) (m HostMeta, err error)
+	GetHostMetaList() (m []HostMeta, err error)
+	PauseHost(p HostPause) error
+	ResumeHost(host string) error
+	GetPausedHosts() (p []HostPause, err error)
+	SetPushMonitor(m PushMonitor) error
+	GetPushMonitor(host string) (m PushMonitor, err error)
+	GetPushMonitorsList() (m []PushMonitor, err error)
+	AddHostHTTPAssertions(a HTTPAssertions) error
+	GetHostHTTPAssertions(host string) (a HTTPAssertions, err error)
+	GetHostHTTPAssertionsList() (a []HTTPAssertions, err error)
+	DeleteHostHTTPAssertions(host string) error
+	AddQueuedNotification(n QueuedNotification) error
+	GetQueuedNotifications() (n []QueuedNotification, err error)
+	UpdateQueuedNotification(n QueuedNotification) error
+	DeleteQueuedNotification(id int64) error
+	AddNotificationLog(l NotificationLogEntry) error
+	GetNotificationLog(host string, limit int) (l []NotificationLogEntry, err error)
+	DeleteOldNotificationLog(beforeTime time.Time) error
+	SetHostState(s StateChangeData) error
+	GetHostStatesList() (s []StateChangeData, err error)
+	AddIncident(i Incident) error
+	GetOpenIncident(host string) (i Incident, err error)
+	CloseIncident(i Incident) error
+	GetIncidents(host string, start time.Time, end time.Time) (i []Incident, err error)
+	AddMaintenanceWindow(m MaintenanceWindow) error
+	GetMaintenanceWindows() (m []MaintenanceWindow, err error)
+	DeleteMaintenanceWindow(id int64) error
 }
 
 var ErrNoHostInDB = errors.New("no such host in DB")
+var ErrDBNotInitialised = errors.New("DB not initialised")
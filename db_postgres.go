@@ -2,6 +2,7 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
 	_ "github.com/lib/pq"
 	"strings"
 	"time"
@@ -38,6 +39,14 @@ func (d *MonDBPQ) Open(cfg Configuration) error {
 		return err
 	}
 
+	//Empty DB is initialised with -init option
+	if migrateOnOpen {
+		_, err = d.Migrate(false)
+		if err != nil && err != ErrDBNotInitialised {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -109,387 +118,527 @@ CREATE INDEX notifications_params_host_idx
 		return err
 	}
 
-	return nil
+	_, err = d.Migrate(false)
+	return err
 }
 
-func (d *MonDBPQ) GetHostsList() (hosts []string, err error) {
-	return GetHostsListCommon(d.db)
+func (d *MonDBPQ) SchemaVersion() (version int64, err error) {
+	return getSchemaVersionCommon(d.db, "SELECT count(*) FROM information_schema.tables WHERE table_schema = 'public' AND table_name = $1;")
 }
 
-func (d *MonDBPQ) AddHost(newHost string) error {
-	return AddHostCommon(d.db, newHost)
+func (d *MonDBPQ) Migrate(dryRun bool) (applied []Migration, err error) {
+	version, err := d.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	return migrateSQLCommon(d.db, version, pqMigrations, pqSchemaVersionTable, dryRun)
 }
 
-func (d *MonDBPQ) DeleteHost(newHost string) error {
-	tx, err := d.db.Begin()
-	if err != nil {
+const pqSchemaVersionTable = "CREATE TABLE IF NOT EXISTS public.schema_version (version bigint NOT NULL);"
+
+var pqMigrations = []sqlMigration{
+	{Migration{2, "Add HTTP assertions"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS public.http_assertions
+(
+  host integer NOT NULL,
+  status_codes text NOT NULL,
+  body_contains text NOT NULL,
+  body_regex text NOT NULL,
+  body_not_contains text NOT NULL,
+  headers text NOT NULL,
+  CONSTRAINT http_assertions_pkey PRIMARY KEY (host),
+  CONSTRAINT http_assertions_host_fkey FOREIGN KEY (host)
+      REFERENCES public.hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+`)
 		return err
-	}
+	}},
+	{Migration{3, "Add host settings"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS public.hosts_settings
+(
+  host integer NOT NULL,
+  check_interval bigint NOT NULL,
+  timeout bigint NOT NULL,
+  retries bigint NOT NULL,
+  method text NOT NULL,
+  CONSTRAINT hosts_settings_pkey PRIMARY KEY (host),
+  CONSTRAINT hosts_settings_host_fkey FOREIGN KEY (host)
+      REFERENCES public.hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+`)
+		return err
+	}},
+	{Migration{4, "Add failure reason, status code and IP to checks"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+ALTER TABLE public.checks
+  ADD COLUMN IF NOT EXISTS reason text NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS status_code integer NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS ip text NOT NULL DEFAULT '';
+`)
+		return err
+	}},
+	{Migration{5, "Add webhook method, headers and body to notification params"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+ALTER TABLE public.notifications_params
+  ADD COLUMN IF NOT EXISTS method text NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS headers text NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS body text NOT NULL DEFAULT '';
+`)
+		return err
+	}},
+	{Migration{6, "Add notifications queue and delivery log"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS public.notifications_queue
+(
+  id SERIAL NOT NULL,
+  host text NOT NULL,
+  method text NOT NULL,
+  url text NOT NULL,
+  headers text NOT NULL,
+  body text NOT NULL,
+  attempts bigint NOT NULL,
+  next_attempt timestamp without time zone NOT NULL,
+  created timestamp without time zone NOT NULL,
+  CONSTRAINT notifications_queue_pkey PRIMARY KEY (id)
+);
 
-	{
-		var stmt *sql.Stmt
-		stmt, err = tx.Prepare("DELETE FROM notifications_params WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);")
-		if err != nil {
-			e := tx.Rollback()
-			if e != nil {
-				return e
-			}
-			return err
-		}
+CREATE TABLE IF NOT EXISTS public.notifications_log
+(
+  host text NOT NULL,
+  log_time timestamp without time zone NOT NULL,
+  method text NOT NULL,
+  url text NOT NULL,
+  attempt bigint NOT NULL,
+  status_code integer NOT NULL,
+  error text NOT NULL,
+  delivered boolean NOT NULL
+);
 
-		_, err = stmt.Exec(newHost)
-		if err != nil {
-			stmt.Close()
-			e := tx.Rollback()
-			if e != nil {
-				return e
-			}
-			return err
-		}
+CREATE INDEX IF NOT EXISTS notifications_log_time_idx
+  ON public.notifications_log
+  USING btree
+  (log_time);
+`)
+		return err
+	}},
+	{Migration{7, "Add host states"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS public.host_states
+(
+  host integer NOT NULL,
+  observed timestamp without time zone NOT NULL,
+  state boolean NOT NULL,
+  change_count bigint NOT NULL,
+  since timestamp without time zone NOT NULL,
+  CONSTRAINT host_states_pkey PRIMARY KEY (host),
+  CONSTRAINT host_states_host_fkey FOREIGN KEY (host)
+      REFERENCES public.hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+`)
+		return err
+	}},
+	{Migration{8, "Add incidents"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS public.incidents
+(
+  id SERIAL NOT NULL,
+  host integer NOT NULL,
+  start_time timestamp without time zone NOT NULL,
+  end_time timestamp without time zone,
+  reason text NOT NULL,
+  checks bigint NOT NULL,
+  CONSTRAINT incidents_pkey PRIMARY KEY (id),
+  CONSTRAINT incidents_host_fkey FOREIGN KEY (host)
+      REFERENCES public.hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
 
-		err = stmt.Close()
-		if err != nil {
-			e := tx.Rollback()
-			if e != nil {
-				return e
-			}
-			return err
-		}
-	}
+CREATE INDEX IF NOT EXISTS incidents_host_idx
+  ON public.incidents
+  USING btree
+  (host, start_time);
+`)
+		return err
+	}},
+	{Migration{9, "Add check rollups"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS public.checks_rollups
+(
+  host integer NOT NULL,
+  resolution text NOT NULL,
+  rollup_time timestamp without time zone NOT NULL,
+  up_count bigint NOT NULL,
+  down_count bigint NOT NULL,
+  rtt_min bigint NOT NULL,
+  rtt_avg bigint NOT NULL,
+  rtt_max bigint NOT NULL,
+  CONSTRAINT checks_rollups_pkey PRIMARY KEY (host, resolution, rollup_time),
+  CONSTRAINT checks_rollups_host_fkey FOREIGN KEY (host)
+      REFERENCES public.hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+`)
+		return err
+	}},
+	{Migration{10, "Add host metadata"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS public.hosts_meta
+(
+  host integer NOT NULL,
+  name text NOT NULL,
+  description text NOT NULL,
+  host_group text NOT NULL,
+  tags text NOT NULL,
+  CONSTRAINT hosts_meta_pkey PRIMARY KEY (host),
+  CONSTRAINT hosts_meta_host_fkey FOREIGN KEY (host)
+      REFERENCES public.hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+`)
+		return err
+	}},
+	{Migration{11, "Add maintenance windows"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+ALTER TABLE public.checks
+  ADD COLUMN IF NOT EXISTS maintenance boolean NOT NULL DEFAULT false;
 
-	{
-		var stmt *sql.Stmt
-		stmt, err = tx.Prepare("DELETE FROM checks WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);")
-		if err != nil {
-			e := tx.Rollback()
-			if e != nil {
-				return e
-			}
-			return err
-		}
+CREATE TABLE IF NOT EXISTS public.maintenance_windows
+(
+  id SERIAL NOT NULL,
+  host text NOT NULL,
+  tag text NOT NULL,
+  start_time timestamp without time zone NOT NULL,
+  end_time timestamp without time zone NOT NULL,
+  schedule text NOT NULL,
+  duration_seconds bigint NOT NULL,
+  description text NOT NULL,
+  CONSTRAINT maintenance_windows_pkey PRIMARY KEY (id)
+);
+`)
+		return err
+	}},
+	{Migration{12, "Add host pauses"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS public.hosts_paused
+(
+  host integer NOT NULL,
+  since timestamp without time zone NOT NULL,
+  resume_at timestamp without time zone,
+  CONSTRAINT hosts_paused_pkey PRIMARY KEY (host),
+  CONSTRAINT hosts_paused_host_fkey FOREIGN KEY (host)
+      REFERENCES public.hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+`)
+		return err
+	}},
+	{Migration{13, "Add push monitors"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS public.push_monitors
+(
+  host integer NOT NULL,
+  token text NOT NULL,
+  grace bigint NOT NULL,
+  created timestamp without time zone NOT NULL,
+  last_ping timestamp without time zone NOT NULL,
+  CONSTRAINT push_monitors_pkey PRIMARY KEY (host),
+  CONSTRAINT push_monitors_token_key UNIQUE (token),
+  CONSTRAINT push_monitors_host_fkey FOREIGN KEY (host)
+      REFERENCES public.hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+`)
+		return err
+	}},
+}
 
-		_, err = stmt.Exec(newHost)
-		if err != nil {
-			stmt.Close()
-			e := tx.Rollback()
-			if e != nil {
-				return e
-			}
-			return err
-		}
+func (d *MonDBPQ) GetHostsList() (hosts []string, err error) {
+	return GetHostsListCommon(d.db)
+}
 
-		err = stmt.Close()
-		if err != nil {
-			e := tx.Rollback()
-			if e != nil {
-				return e
-			}
-			return err
-		}
-	}
+func (d *MonDBPQ) AddHost(newHost string) error {
+	return AddHostCommon(d.db, newHost)
+}
 
-	{
-		var stmt *sql.Stmt
-		stmt, err = tx.Prepare("DELETE FROM hosts WHERE host = $1;")
-		if err != nil {
-			e := tx.Rollback()
-			if e != nil {
-				return e
-			}
-			return err
-		}
+func (d *MonDBPQ) DeleteHost(newHost string) error {
+	return DeleteHostCommon(d.db, newHost)
+}
 
-		_, err = stmt.Exec(newHost)
-		if err != nil {
-			stmt.Close()
-			e := tx.Rollback()
-			if e != nil {
-				return e
-			}
-			return err
-		}
+func (d *MonDBPQ) CheckHostExists(newHost string) error {
+	return CheckHostExistsCommon(d.db, newHost)
+}
 
-		err = stmt.Close()
-		if err != nil {
-			e := tx.Rollback()
+func (d *MonDBPQ) SaveCheck(host string, cData ChecksData) error {
+	return SaveCheckCommon(d.db, host, cData)
+}
+
+// PostgreSQL limits the number of parameters of a statement, so checks are
+// inserted in chunks of pqSaveChecksChunk rows.
+const pqSaveChecksChunk = 1000
+
+// SaveChecks writes all checks in one transaction with multi-row inserts.
+// Checks that are already stored are skipped, so a duplicate does not roll
+// back the whole batch.
+func (d *MonDBPQ) SaveChecks(checks []HostCheck) error {
+	return execTxCommon(d.db, func(tx *sql.Tx) error {
+		for start := 0; start < len(checks); start += pqSaveChecksChunk {
+			end := start + pqSaveChecksChunk
+			if end > len(checks) {
+				end = len(checks)
+			}
+			var sb strings.Builder
+			args := make([]interface{}, 0, (end-start)*8)
+			sb.WriteString("INSERT INTO checks (host, check_time, rtt, up, reason, status_code, ip, maintenance) SELECT hosts.id, v.check_time, v.rtt, v.up, v.reason, v.status_code, v.ip, v.maintenance FROM (VALUES ")
+			for i, c := range checks[start:end] {
+				if i > 0 {
+					sb.WriteString(", ")
+				}
+				n := i * 8
+				fmt.Fprintf(&sb, "($%d::text, $%d::timestamp, $%d::bigint, $%d::boolean, $%d::text, $%d::integer, $%d::text, $%d::boolean)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8)
+				args = append(args, c.Host, c.Timestamp, c.Rtt, c.Up, c.Reason, int64(c.StatusCode), c.IP, c.Maintenance)
+			}
+			sb.WriteString(") AS v (host, check_time, rtt, up, reason, status_code, ip, maintenance) JOIN hosts ON hosts.host = v.host ON CONFLICT DO NOTHING;")
+			e := execStmtCommon(tx, sb.String(), args...)
 			if e != nil {
 				return e
 			}
-			return err
 		}
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
-
-	return nil
+		return nil
+	})
 }
 
-func (d *MonDBPQ) CheckHostExists(newHost string) error {
-	return CheckHostExistsCommon(d.db, newHost)
+func (d *MonDBPQ) GetChecksData(chkReq ChecksRequest) (cData []ChecksData, err error) {
+	return GetChecksDataCommon(d.db, chkReq)
 }
 
-func (d *MonDBPQ) SaveCheck(host string, checkTime time.Time, rtt int64, up bool) error {
-	var err error
+func (d *MonDBPQ) GetLastCheckData(host string) (cData ChecksData, err error) {
+	return GetLastCheckDataCommon(d.db, host)
+}
 
-	var tx *sql.Tx
-	tx, err = d.db.Begin()
-	if err != nil {
-		return err
-	}
+func (d *MonDBPQ) GetFirstCheckData(host string) (cData ChecksData, err error) {
+	return GetFirstCheckDataCommon(d.db, host)
+}
 
+func (d *MonDBPQ) GetChecksStats(host string, start time.Time, end time.Time) (s ChecksStats, err error) {
 	var stmt *sql.Stmt
-	stmt, err = tx.Prepare("INSERT INTO checks (host, check_time, rtt, up) SELECT id, $2, $3, $4 FROM hosts WHERE host = $1 LIMIT 1;")
+	stmt, err = d.db.Prepare(`SELECT count(*), count(*) FILTER (WHERE up),
+	COALESCE(min(rtt) FILTER (WHERE up), 0),
+	COALESCE(avg(rtt) FILTER (WHERE up), 0)::bigint,
+	COALESCE(percentile_disc(0.5) WITHIN GROUP (ORDER BY rtt) FILTER (WHERE up), 0),
+	COALESCE(percentile_disc(0.95) WITHIN GROUP (ORDER BY rtt) FILTER (WHERE up), 0),
+	COALESCE(percentile_disc(0.99) WITHIN GROUP (ORDER BY rtt) FILTER (WHERE up), 0),
+	COALESCE(max(rtt) FILTER (WHERE up), 0)
+	FROM checks WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1) AND check_time >= $2 AND check_time <= $3 AND NOT maintenance;`)
 	if err != nil {
-		e := tx.Rollback()
-		if e != nil {
-			return e
-		}
-		return err
-	}
-
-	_, err = stmt.Exec(host, checkTime, rtt, up)
-	if err != nil {
-		stmt.Close()
-		e := tx.Rollback()
-		if e != nil {
-			return e
-		}
-		return err
-	}
-
-	err = stmt.Close()
-	if err != nil {
-		e := tx.Rollback()
-		if e != nil {
-			return e
-		}
-		return err
+		return s, err
 	}
+	defer stmt.Close()
 
-	err = tx.Commit()
+	row := stmt.QueryRow(host, start, end)
+	err = row.Scan(&s.Checks, &s.UpChecks, &s.RttMin, &s.RttAvg, &s.RttP50, &s.RttP95, &s.RttP99, &s.RttMax)
 	if err != nil {
-		return err
+		return s, err
 	}
-	return nil
+	return s, nil
 }
 
-func (d *MonDBPQ) GetChecksData(chkReq ChecksRequest) (cData []ChecksData, err error) {
-	cData = make([]ChecksData, 0)
+func (d *MonDBPQ) GetMonthlyChecks(start time.Time, end time.Time) (m []MonthlyChecks, err error) {
+	m = make([]MonthlyChecks, 0)
 	var stmt *sql.Stmt
-	stmt, err = d.db.Prepare("SELECT check_time, rtt, up FROM checks WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1) AND check_time >= $2 AND check_time <= $3;")
+	stmt, err = d.db.Prepare("SELECT hosts.host, date_trunc('month', checks.check_time) AS month, count(*), count(*) FILTER (WHERE checks.up) FROM hosts, checks WHERE hosts.id = checks.host AND checks.check_time >= $1 AND checks.check_time <= $2 AND NOT checks.maintenance GROUP BY hosts.host, month;")
 	if err != nil {
-		return cData, err
+		return m, err
 	}
 	defer stmt.Close()
 
 	var rows *sql.Rows
-	rows, err = stmt.Query(chkReq.Host, chkReq.Start, chkReq.End)
+	rows, err = stmt.Query(start, end)
 	if err != nil {
-		return cData, err
+		return m, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var tmpDat ChecksData
-		err := rows.Scan(&tmpDat.Timestamp, &tmpDat.Rtt, &tmpDat.Up)
+		var tmpDat MonthlyChecks
+		err := rows.Scan(&tmpDat.Host, &tmpDat.Month, &tmpDat.Checks, &tmpDat.UpChecks)
 		if err != nil {
-			return cData, err
+			return m, err
 		}
-		cData = append(cData, tmpDat)
+		tmpDat.Month = tmpDat.Month.UTC()
+		m = append(m, tmpDat)
 	}
 	err = rows.Err()
 	if err != nil {
-		return cData, err
+		return m, err
 	}
-	return cData, nil
+	return m, nil
 }
 
-func (d *MonDBPQ) GetLastCheckData(host string) (cData ChecksData, err error) {
-	var stmt *sql.Stmt
-	stmt, err = d.db.Prepare("SELECT check_time, rtt, up FROM checks WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1) ORDER BY check_time DESC LIMIT 1;")
-	if err != nil {
-		return cData, err
-	}
-	defer stmt.Close()
+func (d *MonDBPQ) DeleteOldChecks(beforeTime time.Time) error {
+	return DeleteOldChecksCommon(d.db, beforeTime)
+}
 
-	row := stmt.QueryRow(host)
-	err = row.Scan(&cData.Timestamp, &cData.Rtt, &cData.Up)
-	if err != nil {
-		return cData, err
-	}
-	return cData, nil
+func (d *MonDBPQ) SaveRollups(host string, resolution string, r []RollupData) error {
+	return SaveRollupsCommon(d.db, "hosts.id", host, resolution, r)
 }
 
-func (d *MonDBPQ) DeleteOldChecks(beforeTime time.Time) error {
-	return DeleteOldChecksCommon(d.db, beforeTime)
+func (d *MonDBPQ) GetRollups(chkReq ChecksRequest) (r []RollupData, err error) {
+	return GetRollupsCommon(d.db, "hosts.id", chkReq)
 }
 
-func (d *MonDBPQ) AddHostStateChangeParams(newHost string, newThreshold int64, newAction string) error {
-	tx, err := d.db.Begin()
-	if err != nil {
-		return err
-	}
+func (d *MonDBPQ) GetLastRollup(host string, resolution string) (r RollupData, err error) {
+	return GetLastRollupCommon(d.db, "hosts.id", host, resolution)
+}
 
-	{
-		var stmt *sql.Stmt
-		stmt, err = tx.Prepare("DELETE FROM notifications_params WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);")
-		if err != nil {
-			e := tx.Rollback()
-			if e != nil {
-				return e
-			}
-			return err
-		}
+func (d *MonDBPQ) DeleteOldRollups(resolution string, beforeTime time.Time) error {
+	return DeleteOldRollupsCommon(d.db, resolution, beforeTime)
+}
 
-		_, err = stmt.Exec(newHost)
-		if err != nil {
-			stmt.Close()
-			e := tx.Rollback()
-			if e != nil {
-				return e
-			}
-			return err
-		}
+func (d *MonDBPQ) AddHostStateChangeParams(p StateChangeParams) error {
+	return AddHostStateChangeParamsCommon(d.db, p)
+}
 
-		err = stmt.Close()
-		if err != nil {
-			e := tx.Rollback()
-			if e != nil {
-				return e
-			}
-			return err
-		}
-	}
+func (d *MonDBPQ) GetHostStateChangeParams(host string) (p StateChangeParams, err error) {
+	return GetHostStateChangeParamsCommon(d.db, host)
+}
 
-	{
-		var stmt *sql.Stmt
-		stmt, err = tx.Prepare("INSERT INTO notifications_params (host, change_threshold, action) SELECT id, $2, $3 FROM hosts WHERE host = $1 LIMIT 1;")
-		if err != nil {
-			e := tx.Rollback()
-			if e != nil {
-				return e
-			}
-			return err
-		}
+func (d *MonDBPQ) GetHostStateChangeParamsList() (p []StateChangeParams, err error) {
+	return GetHostStateChangeParamsListCommon(d.db)
+}
 
-		_, err = stmt.Exec(newHost, newThreshold, newAction)
-		if err != nil {
-			stmt.Close()
-			e := tx.Rollback()
-			if e != nil {
-				return e
-			}
-			return err
-		}
+func (d *MonDBPQ) DeleteHostStateChangeParams(newHost string) error {
+	return DeleteHostStateChangeParamsCommon(d.db, newHost)
+}
 
-		err = stmt.Close()
-		if err != nil {
-			e := tx.Rollback()
-			if e != nil {
-				return e
-			}
-			return err
-		}
-	}
+func (d *MonDBPQ) SetHostSettings(s HostSettings) error {
+	return SetHostSettingsCommon(d.db, s)
+}
 
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
+func (d *MonDBPQ) GetHostSettings(host string) (s HostSettings, err error) {
+	return GetHostSettingsCommon(d.db, host)
+}
 
-	return nil
+func (d *MonDBPQ) GetHostSettingsList() (s []HostSettings, err error) {
+	return GetHostSettingsListCommon(d.db)
 }
 
-func (d *MonDBPQ) GetHostStateChangeParams(host string) (p StateChangeParams, err error) {
-	var stmt *sql.Stmt
-	stmt, err = d.db.Prepare("SELECT change_threshold, action FROM notifications_params WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);")
-	if err != nil {
-		return p, err
-	}
-	defer stmt.Close()
+func (d *MonDBPQ) SetHostMeta(m HostMeta) error {
+	return SetHostMetaCommon(d.db, m)
+}
 
-	row := stmt.QueryRow(host)
-	err = row.Scan(&p.ChangeThreshold, &p.Action)
-	p.Host = host
-	if err != nil {
-		if err == sql.ErrNoRows {
-			err = ErrNoHostInDB
-		}
-		return p, err
-	}
-	return p, nil
+func (d *MonDBPQ) GetHostMeta(host string) (m HostMeta, err error) {
+	return GetHostMetaCommon(d.db, host)
 }
 
-func (d *MonDBPQ) GetHostStateChangeParamsList() (p []StateChangeParams, err error) {
-	p = make([]StateChangeParams, 0)
-	var stmt *sql.Stmt
-	stmt, err = d.db.Prepare("SELECT hosts.host, notifications_params.change_threshold, notifications_params.action FROM hosts, notifications_params WHERE hosts.id = notifications_params.host;")
-	if err != nil {
-		return p, err
-	}
-	defer stmt.Close()
-	var rows *sql.Rows
-	rows, err = stmt.Query()
-	if err != nil {
-		return p, err
-	}
-	defer rows.Close()
-	for rows.Next() {
-		var s StateChangeParams
-		err = rows.Scan(&s.Host, &s.ChangeThreshold, &s.Action)
-		if err != nil {
-			return p, err
-		}
-		p = append(p, s)
-	}
-	err = rows.Err()
-	if err != nil {
-		return p, err
-	}
-	return p, nil
+func (d *MonDBPQ) GetHostMetaList() (m []HostMeta, err error) {
+	return GetHostMetaListCommon(d.db)
 }
 
-func (d *MonDBPQ) DeleteHostStateChangeParams(newHost string) error {
-	tx, err := d.db.Begin()
-	if err != nil {
-		return err
-	}
-	var stmt *sql.Stmt
-	stmt, err = tx.Prepare("DELETE FROM notifications_params WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);")
-	if err != nil {
-		e := tx.Rollback()
-		if e != nil {
-			return e
-		}
-		return err
-	}
+func (d *MonDBPQ) PauseHost(p HostPause) error {
+	return PauseHostCommon(d.db, p)
+}
 
-	_, err = stmt.Exec(newHost)
-	if err != nil {
-		stmt.Close()
-		e := tx.Rollback()
-		if e != nil {
-			return e
-		}
-		return err
-	}
+func (d *MonDBPQ) ResumeHost(host string) error {
+	return ResumeHostCommon(d.db, host)
+}
 
-	err = stmt.Close()
-	if err != nil {
-		e := tx.Rollback()
-		if e != nil {
-			return e
-		}
-		return err
-	}
+func (d *MonDBPQ) GetPausedHosts() (p []HostPause, err error) {
+	return GetPausedHostsCommon(d.db, "hosts.id")
+}
 
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
+func (d *MonDBPQ) SetPushMonitor(m PushMonitor) error {
+	return SetPushMonitorCommon(d.db, m)
+}
 
-	return nil
+func (d *MonDBPQ) GetPushMonitor(host string) (m PushMonitor, err error) {
+	return GetPushMonitorCommon(d.db, "hosts.id", host)
+}
+
+func (d *MonDBPQ) GetPushMonitorsList() (m []PushMonitor, err error) {
+	return GetPushMonitorsListCommon(d.db, "hosts.id")
+}
+
+func (d *MonDBPQ) AddHostHTTPAssertions(a HTTPAssertions) error {
+	return AddHostHTTPAssertionsCommon(d.db, a)
+}
+
+func (d *MonDBPQ) GetHostHTTPAssertions(host string) (a HTTPAssertions, err error) {
+	return GetHostHTTPAssertionsCommon(d.db, host)
+}
+
+func (d *MonDBPQ) GetHostHTTPAssertionsList() (a []HTTPAssertions, err error) {
+	return GetHostHTTPAssertionsListCommon(d.db)
+}
+
+func (d *MonDBPQ) DeleteHostHTTPAssertions(host string) error {
+	return DeleteHostHTTPAssertionsCommon(d.db, host)
+}
+
+func (d *MonDBPQ) AddQueuedNotification(n QueuedNotification) error {
+	return AddQueuedNotificationCommon(d.db, n)
+}
+
+func (d *MonDBPQ) GetQueuedNotifications() (n []QueuedNotification, err error) {
+	return GetQueuedNotificationsCommon(d.db, "id")
+}
+
+func (d *MonDBPQ) UpdateQueuedNotification(n QueuedNotification) error {
+	return UpdateQueuedNotificationCommon(d.db, n)
+}
+
+func (d *MonDBPQ) DeleteQueuedNotification(id int64) error {
+	return DeleteQueuedNotificationCommon(d.db, id)
+}
+
+func (d *MonDBPQ) AddNotificationLog(l NotificationLogEntry) error {
+	return AddNotificationLogCommon(d.db, l)
+}
+
+func (d *MonDBPQ) GetNotificationLog(host string, limit int) (l []NotificationLogEntry, err error) {
+	return GetNotificationLogCommon(d.db, host, limit)
+}
+
+func (d *MonDBPQ) DeleteOldNotificationLog(beforeTime time.Time) error {
+	return DeleteOldNotificationLogCommon(d.db, beforeTime)
+}
+
+func (d *MonDBPQ) SetHostState(s StateChangeData) error {
+	return SetHostStateCommon(d.db, s)
+}
+
+func (d *MonDBPQ) GetHostStatesList() (s []StateChangeData, err error) {
+	return GetHostStatesListCommon(d.db)
+}
+
+func (d *MonDBPQ) AddIncident(i Incident) error {
+	return AddIncidentCommon(d.db, i)
+}
+
+func (d *MonDBPQ) GetOpenIncident(host string) (i Incident, err error) {
+	return GetOpenIncidentCommon(d.db, "incidents.id", "hosts.id", host)
+}
+
+func (d *MonDBPQ) CloseIncident(i Incident) error {
+	return CloseIncidentCommon(d.db, i)
+}
+
+func (d *MonDBPQ) GetIncidents(host string, start time.Time, end time.Time) (i []Incident, err error) {
+	return GetIncidentsCommon(d.db, "incidents.id", "hosts.id", host, start, end)
+}
+
+func (d *MonDBPQ) AddMaintenanceWindow(m MaintenanceWindow) error {
+	return AddMaintenanceWindowCommon(d.db, m)
+}
+
+func (d *MonDBPQ) GetMaintenanceWindows() (m []MaintenanceWindow, err error) {
+	return GetMaintenanceWindowsCommon(d.db, "id")
+}
+
+func (d *MonDBPQ) DeleteMaintenanceWindow(id int64) error {
+	return DeleteMaintenanceWindowCommon(d.db, "id", id)
 }
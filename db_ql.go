@@ -39,6 +39,13 @@ func (d *MonDBQL) Open(cfg Configuration) error {
 		return err
 	}
 
+	if migrateOnOpen && !performInit {
+		_, err = d.Migrate(false)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -91,7 +98,234 @@ CREATE TABLE notifications_params
 		return err
 	}
 
-	return nil
+	_, err = d.Migrate(false)
+	return err
+}
+
+func (d *MonDBQL) SchemaVersion() (version int64, err error) {
+	return getSchemaVersionCommon(d.db, "SELECT count(*) FROM __Table WHERE Name = $1;")
+}
+
+func (d *MonDBQL) Migrate(dryRun bool) (applied []Migration, err error) {
+	version, err := d.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	return migrateSQLCommon(d.db, version, qlMigrations, qlSchemaVersionTable, dryRun)
+}
+
+// qlAddColumn adds a column if the table does not have it. ql can not add
+// NOT NULL columns to tables with rows, so existing rows are filled with the
+// default value instead.
+func qlAddColumn(tx *sql.Tx, table string, column string, columnType string, def string) error {
+	var n int64
+	err := tx.QueryRow("SELECT count(*) FROM __Column WHERE TableName = $1 AND Name = $2;", table, column).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err = tx.Exec("ALTER TABLE " + table + " ADD " + column + " " + columnType + " DEFAULT " + def + ";")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE " + table + " SET " + column + " = " + def + " WHERE " + column + " IS NULL;")
+	return err
+}
+
+const qlSchemaVersionTable = "CREATE TABLE IF NOT EXISTS schema_version (version int64 NOT NULL);"
+
+var qlMigrations = []sqlMigration{
+	{Migration{2, "Add HTTP assertions"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS http_assertions
+(
+  host int64 NOT NULL,
+  status_codes string NOT NULL,
+  body_contains string NOT NULL,
+  body_regex string NOT NULL,
+  body_not_contains string NOT NULL,
+  headers string NOT NULL
+);
+`)
+		return err
+	}},
+	{Migration{3, "Add host settings"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS hosts_settings
+(
+  host int64 NOT NULL,
+  check_interval int64 NOT NULL,
+  timeout int64 NOT NULL,
+  retries int64 NOT NULL,
+  method string NOT NULL
+);
+`)
+		return err
+	}},
+	{Migration{4, "Add failure reason, status code and IP to checks"}, func(tx *sql.Tx) error {
+		for _, c := range [][4]string{
+			{"checks", "reason", "string", `""`},
+			{"checks", "status_code", "int64", "0"},
+			{"checks", "ip", "string", `""`},
+		} {
+			err := qlAddColumn(tx, c[0], c[1], c[2], c[3])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{Migration{5, "Add webhook method, headers and body to notification params"}, func(tx *sql.Tx) error {
+		for _, c := range [][4]string{
+			{"notifications_params", "method", "string", `""`},
+			{"notifications_params", "headers", "string", `""`},
+			{"notifications_params", "body", "string", `""`},
+		} {
+			err := qlAddColumn(tx, c[0], c[1], c[2], c[3])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{Migration{6, "Add notifications queue and delivery log"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS notifications_queue
+(
+  host string NOT NULL,
+  method string NOT NULL,
+  url string NOT NULL,
+  headers string NOT NULL,
+  body string NOT NULL,
+  attempts int64 NOT NULL,
+  next_attempt time NOT NULL,
+  created time NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS notifications_log
+(
+  host string NOT NULL,
+  log_time time NOT NULL,
+  method string NOT NULL,
+  url string NOT NULL,
+  attempt int64 NOT NULL,
+  status_code int64 NOT NULL,
+  error string NOT NULL,
+  delivered bool NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS notifications_log_idx ON notifications_log (log_time);
+`)
+		return err
+	}},
+	{Migration{7, "Add host states"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS host_states
+(
+  host int64 NOT NULL,
+  observed time NOT NULL,
+  state bool NOT NULL,
+  change_count int64 NOT NULL,
+  since time NOT NULL
+);
+`)
+		return err
+	}},
+	{Migration{8, "Add incidents"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS incidents
+(
+  host int64 NOT NULL,
+  start_time time NOT NULL,
+  end_time time,
+  reason string NOT NULL,
+  checks int64 NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS incidents_idx ON incidents (host);
+`)
+		return err
+	}},
+	{Migration{9, "Add check rollups"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS checks_rollups
+(
+  host int64 NOT NULL,
+  resolution string NOT NULL,
+  rollup_time time NOT NULL,
+  up_count int64 NOT NULL,
+  down_count int64 NOT NULL,
+  rtt_min int64 NOT NULL,
+  rtt_avg int64 NOT NULL,
+  rtt_max int64 NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS checks_rollups_idx ON checks_rollups (host);
+`)
+		return err
+	}},
+	{Migration{10, "Add host metadata"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS hosts_meta
+(
+  host int64 NOT NULL,
+  name string NOT NULL,
+  description string NOT NULL,
+  host_group string NOT NULL,
+  tags string NOT NULL
+);
+`)
+		return err
+	}},
+	{Migration{11, "Add maintenance windows"}, func(tx *sql.Tx) error {
+		for _, c := range [][4]string{
+			{"checks", "maintenance", "bool", "false"},
+		} {
+			err := qlAddColumn(tx, c[0], c[1], c[2], c[3])
+			if err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS maintenance_windows
+(
+  host string NOT NULL,
+  tag string NOT NULL,
+  start_time time NOT NULL,
+  end_time time NOT NULL,
+  schedule string NOT NULL,
+  duration_seconds int64 NOT NULL,
+  description string NOT NULL
+);
+`)
+		return err
+	}},
+	{Migration{12, "Add host pauses"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS hosts_paused
+(
+  host int64 NOT NULL,
+  since time NOT NULL,
+  resume_at time
+);
+`)
+		return err
+	}},
+	{Migration{13, "Add push monitors"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS push_monitors
+(
+  host int64 NOT NULL,
+  token string NOT NULL,
+  grace int64 NOT NULL,
+  created time NOT NULL,
+  last_ping time NOT NULL
+);
+`)
+		return err
+	}},
 }
 
 func (d *MonDBQL) GetHostsList() (hosts []string, err error) {
@@ -102,15 +336,294 @@ func (d *MonDBQL) AddHost(newHost string) error {
 	return AddHostCommon(d.db, newHost)
 }
 
-func (d *MonDBQL) DeleteHost(newHost string) error {
-	tx, err := d.db.Begin()
-	if err != nil {
-		return err
+func (d *MonDBQL) DeleteHost(newHost string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	{
+		var stmt *sql.Stmt
+		stmt, err = tx.Prepare("DELETE FROM notifications_params WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);")
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		_, err = stmt.Exec(newHost)
+		if err != nil {
+			stmt.Close()
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		err = stmt.Close()
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+	}
+
+	{
+		var stmt *sql.Stmt
+		stmt, err = tx.Prepare("DELETE FROM hosts_settings WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);")
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		_, err = stmt.Exec(newHost)
+		if err != nil {
+			stmt.Close()
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		err = stmt.Close()
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+	}
+
+	{
+		var stmt *sql.Stmt
+		stmt, err = tx.Prepare("DELETE FROM hosts_meta WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);")
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		_, err = stmt.Exec(newHost)
+		if err != nil {
+			stmt.Close()
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		err = stmt.Close()
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+	}
+
+	{
+		var stmt *sql.Stmt
+		stmt, err = tx.Prepare("DELETE FROM hosts_paused WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);")
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		_, err = stmt.Exec(newHost)
+		if err != nil {
+			stmt.Close()
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		err = stmt.Close()
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+	}
+
+	{
+		var stmt *sql.Stmt
+		stmt, err = tx.Prepare("DELETE FROM push_monitors WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);")
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		_, err = stmt.Exec(newHost)
+		if err != nil {
+			stmt.Close()
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		err = stmt.Close()
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+	}
+
+	{
+		var stmt *sql.Stmt
+		stmt, err = tx.Prepare("DELETE FROM maintenance_windows WHERE host = $1;")
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		_, err = stmt.Exec(newHost)
+		if err != nil {
+			stmt.Close()
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		err = stmt.Close()
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+	}
+
+	{
+		var stmt *sql.Stmt
+		stmt, err = tx.Prepare("DELETE FROM http_assertions WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);")
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		_, err = stmt.Exec(newHost)
+		if err != nil {
+			stmt.Close()
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		err = stmt.Close()
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+	}
+
+	{
+		var stmt *sql.Stmt
+		stmt, err = tx.Prepare("DELETE FROM host_states WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);")
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		_, err = stmt.Exec(newHost)
+		if err != nil {
+			stmt.Close()
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		err = stmt.Close()
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+	}
+
+	{
+		var stmt *sql.Stmt
+		stmt, err = tx.Prepare("DELETE FROM incidents WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);")
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		_, err = stmt.Exec(newHost)
+		if err != nil {
+			stmt.Close()
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
+
+		err = stmt.Close()
+		if err != nil {
+			e := tx.Rollback()
+			if e != nil {
+				return e
+			}
+			return err
+		}
 	}
 
 	{
 		var stmt *sql.Stmt
-		stmt, err = tx.Prepare("DELETE FROM notifications_params WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);")
+		stmt, err = tx.Prepare("DELETE FROM checks_rollups WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);")
 		if err != nil {
 			e := tx.Rollback()
 			if e != nil {
@@ -213,7 +726,7 @@ func (d *MonDBQL) CheckHostExists(newHost string) error {
 	return CheckHostExistsCommon(d.db, newHost)
 }
 
-func (d *MonDBQL) SaveCheck(host string, checkTime time.Time, rtt int64, up bool) error {
+func (d *MonDBQL) SaveCheck(host string, cData ChecksData) error {
 	var err error
 
 	var tx *sql.Tx
@@ -223,7 +736,7 @@ func (d *MonDBQL) SaveCheck(host string, checkTime time.Time, rtt int64, up bool
 	}
 
 	var stmt *sql.Stmt
-	stmt, err = tx.Prepare("INSERT INTO checks (host, check_time, rtt, up) SELECT id(), $2, $3, $4 FROM hosts WHERE host = $1 LIMIT 1;")
+	stmt, err = tx.Prepare("INSERT INTO checks (host, check_time, rtt, up, reason, status_code, ip, maintenance) SELECT id(), $2, $3, $4, $5, $6, $7, $8 FROM hosts WHERE host = $1 LIMIT 1;")
 	if err != nil {
 		e := tx.Rollback()
 		if e != nil {
@@ -232,7 +745,7 @@ func (d *MonDBQL) SaveCheck(host string, checkTime time.Time, rtt int64, up bool
 		return err
 	}
 
-	_, err = stmt.Exec(host, checkTime, rtt, up)
+	_, err = stmt.Exec(host, cData.Timestamp, cData.Rtt, cData.Up, cData.Reason, int64(cData.StatusCode), cData.IP, cData.Maintenance)
 	if err != nil {
 		stmt.Close()
 		e := tx.Rollback()
@@ -258,10 +771,27 @@ func (d *MonDBQL) SaveCheck(host string, checkTime time.Time, rtt int64, up bool
 	return nil
 }
 
+func (d *MonDBQL) SaveChecks(checks []HostCheck) error {
+	return execTxCommon(d.db, func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare("INSERT INTO checks (host, check_time, rtt, up, reason, status_code, ip, maintenance) SELECT id(), $2, $3, $4, $5, $6, $7, $8 FROM hosts WHERE host = $1 LIMIT 1;")
+		if err != nil {
+			return err
+		}
+		for _, c := range checks {
+			_, err = stmt.Exec(c.Host, c.Timestamp, c.Rtt, c.Up, c.Reason, int64(c.StatusCode), c.IP, c.Maintenance)
+			if err != nil {
+				stmt.Close()
+				return err
+			}
+		}
+		return stmt.Close()
+	})
+}
+
 func (d *MonDBQL) GetChecksData(chkReq ChecksRequest) (cData []ChecksData, err error) {
 	cData = make([]ChecksData, 0)
 	var stmt *sql.Stmt
-	stmt, err = d.db.Prepare("SELECT check_time, rtt, up FROM checks WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1) AND check_time >= $2 AND check_time <= $3;")
+	stmt, err = d.db.Prepare("SELECT check_time, rtt, up, reason, status_code, ip, maintenance FROM checks WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1) AND check_time >= $2 AND check_time <= $3;")
 	if err != nil {
 		return cData, err
 	}
@@ -276,7 +806,7 @@ func (d *MonDBQL) GetChecksData(chkReq ChecksRequest) (cData []ChecksData, err e
 
 	for rows.Next() {
 		var tmpDat ChecksData
-		err := rows.Scan(&tmpDat.Timestamp, &tmpDat.Rtt, &tmpDat.Up)
+		err := rows.Scan(&tmpDat.Timestamp, &tmpDat.Rtt, &tmpDat.Up, &tmpDat.Reason, &tmpDat.StatusCode, &tmpDat.IP, &tmpDat.Maintenance)
 		if err != nil {
 			return cData, err
 		}
@@ -291,25 +821,75 @@ func (d *MonDBQL) GetChecksData(chkReq ChecksRequest) (cData []ChecksData, err e
 
 func (d *MonDBQL) GetLastCheckData(host string) (cData ChecksData, err error) {
 	var stmt *sql.Stmt
-	stmt, err = d.db.Prepare("SELECT check_time, rtt, up FROM checks WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1) ORDER BY check_time DESC LIMIT 1;")
+	stmt, err = d.db.Prepare("SELECT check_time, rtt, up, reason, status_code, ip, maintenance FROM checks WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1) ORDER BY check_time DESC LIMIT 1;")
+	if err != nil {
+		return cData, err
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRow(host)
+	err = row.Scan(&cData.Timestamp, &cData.Rtt, &cData.Up, &cData.Reason, &cData.StatusCode, &cData.IP, &cData.Maintenance)
+	if err != nil {
+		return cData, err
+	}
+	return cData, nil
+}
+
+func (d *MonDBQL) GetFirstCheckData(host string) (cData ChecksData, err error) {
+	var stmt *sql.Stmt
+	stmt, err = d.db.Prepare("SELECT check_time, rtt, up, reason, status_code, ip, maintenance FROM checks WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1) ORDER BY check_time LIMIT 1;")
 	if err != nil {
 		return cData, err
 	}
 	defer stmt.Close()
 
 	row := stmt.QueryRow(host)
-	err = row.Scan(&cData.Timestamp, &cData.Rtt, &cData.Up)
+	err = row.Scan(&cData.Timestamp, &cData.Rtt, &cData.Up, &cData.Reason, &cData.StatusCode, &cData.IP, &cData.Maintenance)
 	if err != nil {
 		return cData, err
 	}
 	return cData, nil
 }
 
+func (d *MonDBQL) GetChecksStats(host string, start time.Time, end time.Time) (s ChecksStats, err error) {
+	cData, err := d.GetChecksData(ChecksRequest{Host: host, Start: start, End: end})
+	if err != nil {
+		return s, err
+	}
+	return computeChecksStats(cData), nil
+}
+
+func (d *MonDBQL) GetMonthlyChecks(start time.Time, end time.Time) (m []MonthlyChecks, err error) {
+	return computeMonthlyChecks(d, start, end)
+}
+
 func (d *MonDBQL) DeleteOldChecks(beforeTime time.Time) error {
 	return DeleteOldChecksCommon(d.db, beforeTime)
 }
 
-func (d *MonDBQL) AddHostStateChangeParams(newHost string, newThreshold int64, newAction string) error {
+func (d *MonDBQL) SaveRollups(host string, resolution string, r []RollupData) error {
+	return SaveRollupsCommon(d.db, "id(hosts)", host, resolution, r)
+}
+
+func (d *MonDBQL) GetRollups(chkReq ChecksRequest) (r []RollupData, err error) {
+	return GetRollupsCommon(d.db, "id(hosts)", chkReq)
+}
+
+func (d *MonDBQL) GetLastRollup(host string, resolution string) (r RollupData, err error) {
+	return GetLastRollupCommon(d.db, "id(hosts)", host, resolution)
+}
+
+func (d *MonDBQL) DeleteOldRollups(resolution string, beforeTime time.Time) error {
+	return DeleteOldRollupsCommon(d.db, resolution, beforeTime)
+}
+
+func (d *MonDBQL) AddHostStateChangeParams(p StateChangeParams) error {
+	headers, err := HeadersToString(p.Headers)
+	if err != nil {
+		return err
+	}
+
+
 	tx, err := d.db.Begin()
 	if err != nil {
 		return err
@@ -326,7 +906,7 @@ func (d *MonDBQL) AddHostStateChangeParams(newHost string, newThreshold int64, n
 			return err
 		}
 
-		_, err = stmt.Exec(newHost)
+		_, err = stmt.Exec(p.Host)
 		if err != nil {
 			stmt.Close()
 			e := tx.Rollback()
@@ -348,7 +928,7 @@ func (d *MonDBQL) AddHostStateChangeParams(newHost string, newThreshold int64, n
 
 	{
 		var stmt *sql.Stmt
-		stmt, err = tx.Prepare("INSERT INTO notifications_params (host, change_threshold, action) SELECT id(), $2, $3 FROM hosts WHERE host = $1 LIMIT 1;")
+		stmt, err = tx.Prepare("INSERT INTO notifications_params (host, change_threshold, action, method, headers, body) SELECT id(), $2, $3, $4, $5, $6 FROM hosts WHERE host = $1 LIMIT 1;")
 		if err != nil {
 			e := tx.Rollback()
 			if e != nil {
@@ -357,7 +937,7 @@ func (d *MonDBQL) AddHostStateChangeParams(newHost string, newThreshold int64, n
 			return err
 		}
 
-		_, err = stmt.Exec(newHost, newThreshold, newAction)
+		_, err = stmt.Exec(p.Host, p.ChangeThreshold, p.Action, p.Method, headers, p.Body)
 		if err != nil {
 			stmt.Close()
 			e := tx.Rollback()
@@ -387,14 +967,15 @@ func (d *MonDBQL) AddHostStateChangeParams(newHost string, newThreshold int64, n
 
 func (d *MonDBQL) GetHostStateChangeParams(host string) (p StateChangeParams, err error) {
 	var stmt *sql.Stmt
-	stmt, err = d.db.Prepare("SELECT change_threshold, action FROM notifications_params WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);")
+	stmt, err = d.db.Prepare("SELECT change_threshold, action, method, headers, body FROM notifications_params WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);")
 	if err != nil {
 		return p, err
 	}
 	defer stmt.Close()
 
+	var headers string
 	row := stmt.QueryRow(host)
-	err = row.Scan(&p.ChangeThreshold, &p.Action)
+	err = row.Scan(&p.ChangeThreshold, &p.Action, &p.Method, &headers, &p.Body)
 	p.Host = host
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -402,13 +983,17 @@ func (d *MonDBQL) GetHostStateChangeParams(host string) (p StateChangeParams, er
 		}
 		return p, err
 	}
+	p.Headers, err = HeadersFromString(headers)
+	if err != nil {
+		return p, err
+	}
 	return p, nil
 }
 
 func (d *MonDBQL) GetHostStateChangeParamsList() (p []StateChangeParams, err error) {
 	p = make([]StateChangeParams, 0)
 	var stmt *sql.Stmt
-	stmt, err = d.db.Prepare("SELECT hosts.host, notifications_params.change_threshold, notifications_params.action FROM hosts, notifications_params WHERE id(hosts) = notifications_params.host;")
+	stmt, err = d.db.Prepare("SELECT hosts.host, notifications_params.change_threshold, notifications_params.action, notifications_params.method, notifications_params.headers, notifications_params.body FROM hosts, notifications_params WHERE id(hosts) = notifications_params.host;")
 	if err != nil {
 		return p, err
 	}
@@ -421,7 +1006,12 @@ func (d *MonDBQL) GetHostStateChangeParamsList() (p []StateChangeParams, err err
 	defer rows.Close()
 	for rows.Next() {
 		var s StateChangeParams
-		err = rows.Scan(&s.Host, &s.ChangeThreshold, &s.Action)
+		var headers string
+		err = rows.Scan(&s.Host, &s.ChangeThreshold, &s.Action, &s.Method, &headers, &s.Body)
+		if err != nil {
+			return p, err
+		}
+		s.Headers, err = HeadersFromString(headers)
 		if err != nil {
 			return p, err
 		}
@@ -475,3 +1065,363 @@ func (d *MonDBQL) DeleteHostStateChangeParams(newHost string) error {
 
 	return nil
 }
+
+func (d *MonDBQL) SetHostSettings(s HostSettings) error {
+	return execTxCommon(d.db, func(tx *sql.Tx) error {
+		e := execStmtCommon(tx, "DELETE FROM hosts_settings WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);", s.Host)
+		if e != nil {
+			return e
+		}
+		return execStmtCommon(tx, "INSERT INTO hosts_settings (host, check_interval, timeout, retries, method) SELECT id(), $2, $3, $4, $5 FROM hosts WHERE host = $1 LIMIT 1;",
+			s.Host, s.Interval, s.Timeout, int64(s.Retries), s.Method)
+	})
+}
+
+func (d *MonDBQL) GetHostSettings(host string) (s HostSettings, err error) {
+	var stmt *sql.Stmt
+	stmt, err = d.db.Prepare("SELECT check_interval, timeout, retries, method FROM hosts_settings WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);")
+	if err != nil {
+		return s, err
+	}
+	defer stmt.Close()
+
+	var retries int64
+	row := stmt.QueryRow(host)
+	err = row.Scan(&s.Interval, &s.Timeout, &retries, &s.Method)
+	s.Host = host
+	s.Retries = uint32(retries)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrNoHostInDB
+		}
+		return s, err
+	}
+	return s, nil
+}
+
+func (d *MonDBQL) GetHostSettingsList() (s []HostSettings, err error) {
+	s = make([]HostSettings, 0)
+	var stmt *sql.Stmt
+	stmt, err = d.db.Prepare("SELECT hosts.host, hosts_settings.check_interval, hosts_settings.timeout, hosts_settings.retries, hosts_settings.method FROM hosts, hosts_settings WHERE id(hosts) = hosts_settings.host;")
+	if err != nil {
+		return s, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query()
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hs HostSettings
+		var retries int64
+		err = rows.Scan(&hs.Host, &hs.Interval, &hs.Timeout, &retries, &hs.Method)
+		if err != nil {
+			return s, err
+		}
+		hs.Retries = uint32(retries)
+		s = append(s, hs)
+	}
+	err = rows.Err()
+	if err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+func (d *MonDBQL) SetHostMeta(m HostMeta) error {
+	return execTxCommon(d.db, func(tx *sql.Tx) error {
+		e := execStmtCommon(tx, "DELETE FROM hosts_meta WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);", m.Host)
+		if e != nil {
+			return e
+		}
+		return execStmtCommon(tx, "INSERT INTO hosts_meta (host, name, description, host_group, tags) SELECT id(), $2, $3, $4, $5 FROM hosts WHERE host = $1 LIMIT 1;",
+			m.Host, m.Name, m.Description, m.Group, TagsToString(m.Tags))
+	})
+}
+
+func (d *MonDBQL) GetHostMeta(host string) (m HostMeta, err error) {
+	var stmt *sql.Stmt
+	stmt, err = d.db.Prepare("SELECT name, description, host_group, tags FROM hosts_meta WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);")
+	if err != nil {
+		return m, err
+	}
+	defer stmt.Close()
+
+	var tags string
+	row := stmt.QueryRow(host)
+	err = row.Scan(&m.Name, &m.Description, &m.Group, &tags)
+	m.Host = host
+	m.Tags = TagsFromString(tags)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrNoHostInDB
+		}
+		return m, err
+	}
+	return m, nil
+}
+
+func (d *MonDBQL) GetHostMetaList() (m []HostMeta, err error) {
+	m = make([]HostMeta, 0)
+	var stmt *sql.Stmt
+	stmt, err = d.db.Prepare("SELECT hosts.host, hosts_meta.name, hosts_meta.description, hosts_meta.host_group, hosts_meta.tags FROM hosts, hosts_meta WHERE id(hosts) = hosts_meta.host;")
+	if err != nil {
+		return m, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query()
+	if err != nil {
+		return m, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hm HostMeta
+		var tags string
+		err = rows.Scan(&hm.Host, &hm.Name, &hm.Description, &hm.Group, &tags)
+		if err != nil {
+			return m, err
+		}
+		hm.Tags = TagsFromString(tags)
+		m = append(m, hm)
+	}
+	err = rows.Err()
+	if err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+func (d *MonDBQL) PauseHost(p HostPause) error {
+	return execTxCommon(d.db, func(tx *sql.Tx) error {
+		e := execStmtCommon(tx, "DELETE FROM hosts_paused WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);", p.Host)
+		if e != nil {
+			return e
+		}
+		return execStmtCommon(tx, "INSERT INTO hosts_paused (host, since, resume_at) SELECT id(), $2, $3 FROM hosts WHERE host = $1 LIMIT 1;",
+			p.Host, p.Since, p.ResumeAt)
+	})
+}
+
+func (d *MonDBQL) ResumeHost(host string) error {
+	return execTxCommon(d.db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "DELETE FROM hosts_paused WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);", host)
+	})
+}
+
+func (d *MonDBQL) GetPausedHosts() (p []HostPause, err error) {
+	return GetPausedHostsCommon(d.db, "id(hosts)")
+}
+
+func (d *MonDBQL) SetPushMonitor(m PushMonitor) error {
+	return execTxCommon(d.db, func(tx *sql.Tx) error {
+		e := execStmtCommon(tx, "DELETE FROM push_monitors WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);", m.Host)
+		if e != nil {
+			return e
+		}
+		return execStmtCommon(tx, "INSERT INTO push_monitors (host, token, grace, created, last_ping) SELECT id(), $2, $3, $4, $5 FROM hosts WHERE host = $1 LIMIT 1;",
+			m.Host, m.Token, m.Grace, m.Created, m.LastPing)
+	})
+}
+
+func (d *MonDBQL) GetPushMonitor(host string) (m PushMonitor, err error) {
+	return GetPushMonitorCommon(d.db, "id(hosts)", host)
+}
+
+func (d *MonDBQL) GetPushMonitorsList() (m []PushMonitor, err error) {
+	return GetPushMonitorsListCommon(d.db, "id(hosts)")
+}
+
+func (d *MonDBQL) AddHostHTTPAssertions(a HTTPAssertions) error {
+	headers, err := HeadersToString(a.Headers)
+	if err != nil {
+		return err
+	}
+	return execTxCommon(d.db, func(tx *sql.Tx) error {
+		e := execStmtCommon(tx, "DELETE FROM http_assertions WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);", a.Host)
+		if e != nil {
+			return e
+		}
+		return execStmtCommon(tx, "INSERT INTO http_assertions (host, status_codes, body_contains, body_regex, body_not_contains, headers) SELECT id(), $2, $3, $4, $5, $6 FROM hosts WHERE host = $1 LIMIT 1;",
+			a.Host, StatusCodesToString(a.StatusCodes), a.BodyContains, a.BodyRegex, a.BodyNotContains, headers)
+	})
+}
+
+func (d *MonDBQL) GetHostHTTPAssertions(host string) (a HTTPAssertions, err error) {
+	var stmt *sql.Stmt
+	stmt, err = d.db.Prepare("SELECT status_codes, body_contains, body_regex, body_not_contains, headers FROM http_assertions WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);")
+	if err != nil {
+		return a, err
+	}
+	defer stmt.Close()
+
+	var codes, headers string
+	row := stmt.QueryRow(host)
+	err = row.Scan(&codes, &a.BodyContains, &a.BodyRegex, &a.BodyNotContains, &headers)
+	a.Host = host
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrNoHostInDB
+		}
+		return a, err
+	}
+	a.StatusCodes, err = StatusCodesFromString(codes)
+	if err != nil {
+		return a, err
+	}
+	a.Headers, err = HeadersFromString(headers)
+	return a, err
+}
+
+func (d *MonDBQL) GetHostHTTPAssertionsList() (a []HTTPAssertions, err error) {
+	a = make([]HTTPAssertions, 0)
+	var stmt *sql.Stmt
+	stmt, err = d.db.Prepare("SELECT hosts.host, http_assertions.status_codes, http_assertions.body_contains, http_assertions.body_regex, http_assertions.body_not_contains, http_assertions.headers FROM hosts, http_assertions WHERE id(hosts) = http_assertions.host;")
+	if err != nil {
+		return a, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query()
+	if err != nil {
+		return a, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s HTTPAssertions
+		var codes, headers string
+		err = rows.Scan(&s.Host, &codes, &s.BodyContains, &s.BodyRegex, &s.BodyNotContains, &headers)
+		if err != nil {
+			return a, err
+		}
+		s.StatusCodes, err = StatusCodesFromString(codes)
+		if err != nil {
+			return a, err
+		}
+		s.Headers, err = HeadersFromString(headers)
+		if err != nil {
+			return a, err
+		}
+		a = append(a, s)
+	}
+	err = rows.Err()
+	if err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+func (d *MonDBQL) DeleteHostHTTPAssertions(host string) error {
+	return execTxCommon(d.db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "DELETE FROM http_assertions WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);", host)
+	})
+}
+
+func (d *MonDBQL) AddQueuedNotification(n QueuedNotification) error {
+	return AddQueuedNotificationCommon(d.db, n)
+}
+
+func (d *MonDBQL) GetQueuedNotifications() (n []QueuedNotification, err error) {
+	return GetQueuedNotificationsCommon(d.db, "id()")
+}
+
+func (d *MonDBQL) UpdateQueuedNotification(n QueuedNotification) error {
+	return execTxCommon(d.db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "UPDATE notifications_queue SET attempts = $2, next_attempt = $3 WHERE id() = $1;", n.ID, n.Attempts, n.NextAttempt)
+	})
+}
+
+func (d *MonDBQL) DeleteQueuedNotification(id int64) error {
+	return execTxCommon(d.db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "DELETE FROM notifications_queue WHERE id() = $1;", id)
+	})
+}
+
+func (d *MonDBQL) AddNotificationLog(l NotificationLogEntry) error {
+	return AddNotificationLogCommon(d.db, l)
+}
+
+func (d *MonDBQL) GetNotificationLog(host string, limit int) (l []NotificationLogEntry, err error) {
+	return GetNotificationLogCommon(d.db, host, limit)
+}
+
+func (d *MonDBQL) DeleteOldNotificationLog(beforeTime time.Time) error {
+	return DeleteOldNotificationLogCommon(d.db, beforeTime)
+}
+
+func (d *MonDBQL) SetHostState(s StateChangeData) error {
+	return execTxCommon(d.db, func(tx *sql.Tx) error {
+		e := execStmtCommon(tx, "DELETE FROM host_states WHERE host IN (SELECT id() FROM hosts WHERE host = $1 LIMIT 1);", s.Host)
+		if e != nil {
+			return e
+		}
+		return execStmtCommon(tx, "INSERT INTO host_states (host, observed, state, change_count, since) SELECT id(), $2, $3, $4, $5 FROM hosts WHERE host = $1 LIMIT 1;",
+			s.Host, s.LastTimeObserved, s.State, s.ChangeCount, s.Since)
+	})
+}
+
+func (d *MonDBQL) GetHostStatesList() (s []StateChangeData, err error) {
+	s = make([]StateChangeData, 0)
+	var stmt *sql.Stmt
+	stmt, err = d.db.Prepare("SELECT hosts.host, host_states.observed, host_states.state, host_states.change_count, host_states.since FROM hosts, host_states WHERE id(hosts) = host_states.host;")
+	if err != nil {
+		return s, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query()
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hs StateChangeData
+		err = rows.Scan(&hs.Host, &hs.LastTimeObserved, &hs.State, &hs.ChangeCount, &hs.Since)
+		if err != nil {
+			return s, err
+		}
+		hs.LastTimeObserved = hs.LastTimeObserved.UTC()
+		hs.Since = hs.Since.UTC()
+		s = append(s, hs)
+	}
+	err = rows.Err()
+	if err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+func (d *MonDBQL) AddIncident(i Incident) error {
+	return execTxCommon(d.db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "INSERT INTO incidents (host, start_time, reason, checks) SELECT id(), $2, $3, $4 FROM hosts WHERE host = $1 LIMIT 1;",
+			i.Host, i.Start, i.Reason, i.Checks)
+	})
+}
+
+func (d *MonDBQL) GetOpenIncident(host string) (i Incident, err error) {
+	return GetOpenIncidentCommon(d.db, "id(incidents)", "id(hosts)", host)
+}
+
+func (d *MonDBQL) CloseIncident(i Incident) error {
+	return execTxCommon(d.db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "UPDATE incidents SET end_time = $2, checks = $3 WHERE id() = $1;", i.ID, *i.End, i.Checks)
+	})
+}
+
+func (d *MonDBQL) GetIncidents(host string, start time.Time, end time.Time) (i []Incident, err error) {
+	return GetIncidentsCommon(d.db, "id(incidents)", "id(hosts)", host, start, end)
+}
+
+func (d *MonDBQL) AddMaintenanceWindow(m MaintenanceWindow) error {
+	return AddMaintenanceWindowCommon(d.db, m)
+}
+
+func (d *MonDBQL) GetMaintenanceWindows() (m []MaintenanceWindow, err error) {
+	return GetMaintenanceWindowsCommon(d.db, "id()")
+}
+
+func (d *MonDBQL) DeleteMaintenanceWindow(id int64) error {
+	return DeleteMaintenanceWindowCommon(d.db, "id()", id)
+}
@@ -135,3 +135,922 @@ func DeleteOldChecksCommon(db *sql.DB, beforeTime time.Time) error {
 
 	return nil
 }
+
+// execTxCommon runs f in a transaction which is rolled back if f fails.
+func execTxCommon(db *sql.DB, f func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+	if err != nil {
+		e := tx.Rollback()
+		if e != nil {
+			return e
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// execStmtCommon prepares and executes a single statement inside tx.
+func execStmtCommon(tx *sql.Tx, query string, args ...interface{}) error {
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(args...)
+	if err != nil {
+		stmt.Close()
+		return err
+	}
+
+	return stmt.Close()
+}
+
+func AddQueuedNotificationCommon(db *sql.DB, n QueuedNotification) error {
+	headers, err := HeadersToString(n.Headers)
+	if err != nil {
+		return err
+	}
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "INSERT INTO notifications_queue (host, method, url, headers, body, attempts, next_attempt, created) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);",
+			n.Host, n.Method, n.URL, headers, n.Body, n.Attempts, n.NextAttempt, n.Created)
+	})
+}
+
+// GetQueuedNotificationsCommon returns the queue ordered by creation time.
+// idColumn is the expression that selects the row id.
+func GetQueuedNotificationsCommon(db *sql.DB, idColumn string) (n []QueuedNotification, err error) {
+	n = make([]QueuedNotification, 0)
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT " + idColumn + ", host, method, url, headers, body, attempts, next_attempt, created FROM notifications_queue ORDER BY created;")
+	if err != nil {
+		return n, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query()
+	if err != nil {
+		return n, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var q QueuedNotification
+		var headers string
+		err = rows.Scan(&q.ID, &q.Host, &q.Method, &q.URL, &headers, &q.Body, &q.Attempts, &q.NextAttempt, &q.Created)
+		if err != nil {
+			return n, err
+		}
+		q.Headers, err = HeadersFromString(headers)
+		if err != nil {
+			return n, err
+		}
+		q.NextAttempt = q.NextAttempt.UTC()
+		q.Created = q.Created.UTC()
+		n = append(n, q)
+	}
+	err = rows.Err()
+	if err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+func AddNotificationLogCommon(db *sql.DB, l NotificationLogEntry) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "INSERT INTO notifications_log (host, log_time, method, url, attempt, status_code, error, delivered) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);",
+			l.Host, l.Time, l.Method, l.URL, l.Attempt, int64(l.StatusCode), l.Error, l.Delivered)
+	})
+}
+
+// GetNotificationLogCommon returns up to limit newest log entries. All hosts
+// are returned if host is empty.
+func GetNotificationLogCommon(db *sql.DB, host string, limit int) (l []NotificationLogEntry, err error) {
+	l = make([]NotificationLogEntry, 0)
+	var stmt *sql.Stmt
+	var rows *sql.Rows
+	if len(host) > 0 {
+		stmt, err = db.Prepare("SELECT host, log_time, method, url, attempt, status_code, error, delivered FROM notifications_log WHERE host = $1 ORDER BY log_time DESC LIMIT $2;")
+		if err != nil {
+			return l, err
+		}
+		defer stmt.Close()
+		rows, err = stmt.Query(host, int64(limit))
+	} else {
+		stmt, err = db.Prepare("SELECT host, log_time, method, url, attempt, status_code, error, delivered FROM notifications_log ORDER BY log_time DESC LIMIT $1;")
+		if err != nil {
+			return l, err
+		}
+		defer stmt.Close()
+		rows, err = stmt.Query(int64(limit))
+	}
+	if err != nil {
+		return l, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e NotificationLogEntry
+		err = rows.Scan(&e.Host, &e.Time, &e.Method, &e.URL, &e.Attempt, &e.StatusCode, &e.Error, &e.Delivered)
+		if err != nil {
+			return l, err
+		}
+		e.Time = e.Time.UTC()
+		l = append(l, e)
+	}
+	err = rows.Err()
+	if err != nil {
+		return l, err
+	}
+	return l, nil
+}
+
+func DeleteOldNotificationLogCommon(db *sql.DB, beforeTime time.Time) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "DELETE FROM notifications_log WHERE log_time < $1;", beforeTime)
+	})
+}
+
+// scanIncidentsCommon reads rows of id, host, start_time, end_time, reason
+// and checks.
+func scanIncidentsCommon(rows *sql.Rows) (incidents []Incident, err error) {
+	incidents = make([]Incident, 0)
+	for rows.Next() {
+		var i Incident
+		var end sql.NullTime
+		err = rows.Scan(&i.ID, &i.Host, &i.Start, &end, &i.Reason, &i.Checks)
+		if err != nil {
+			return incidents, err
+		}
+		i.Start = i.Start.UTC()
+		if end.Valid {
+			t := end.Time.UTC()
+			i.End = &t
+		}
+		incidents = append(incidents, i)
+	}
+	err = rows.Err()
+	if err != nil {
+		return incidents, err
+	}
+	return incidents, nil
+}
+
+// GetIncidentsCommon returns incidents that overlap the period. idColumn and
+// hostIdColumn are the expressions that select incidents and hosts row ids.
+func GetIncidentsCommon(db *sql.DB, idColumn string, hostIdColumn string, host string, start time.Time, end time.Time) (incidents []Incident, err error) {
+	query := "SELECT " + idColumn + ", hosts.host, incidents.start_time, incidents.end_time, incidents.reason, incidents.checks FROM hosts, incidents WHERE " + hostIdColumn + " = incidents.host AND incidents.start_time <= $1 AND (incidents.end_time IS NULL OR incidents.end_time >= $2)"
+	args := []interface{}{end, start}
+	if len(host) > 0 {
+		query += " AND hosts.host = $3"
+		args = append(args, host)
+	}
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare(query + ";")
+	if err != nil {
+		return incidents, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query(args...)
+	if err != nil {
+		return incidents, err
+	}
+	defer rows.Close()
+	return scanIncidentsCommon(rows)
+}
+
+func GetOpenIncidentCommon(db *sql.DB, idColumn string, hostIdColumn string, host string) (i Incident, err error) {
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT " + idColumn + ", hosts.host, incidents.start_time, incidents.end_time, incidents.reason, incidents.checks FROM hosts, incidents WHERE " + hostIdColumn + " = incidents.host AND hosts.host = $1 AND incidents.end_time IS NULL ORDER BY incidents.start_time DESC LIMIT 1;")
+	if err != nil {
+		return i, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query(host)
+	if err != nil {
+		return i, err
+	}
+	defer rows.Close()
+	var incidents []Incident
+	incidents, err = scanIncidentsCommon(rows)
+	if err != nil {
+		return i, err
+	}
+	if len(incidents) == 0 {
+		return i, ErrNoIncident
+	}
+	return incidents[0], nil
+}
+
+// SaveRollupsCommon replaces stored rollups of host with r. hostIdColumn
+// is the hosts id expression of the DB.
+func SaveRollupsCommon(db *sql.DB, hostIdColumn string, host string, resolution string, r []RollupData) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		for _, rd := range r {
+			e := execStmtCommon(tx, "DELETE FROM checks_rollups WHERE host IN (SELECT "+hostIdColumn+" FROM hosts WHERE host = $1 LIMIT 1) AND resolution = $2 AND rollup_time = $3;", host, resolution, rd.Timestamp)
+			if e != nil {
+				return e
+			}
+			e = execStmtCommon(tx, "INSERT INTO checks_rollups (host, resolution, rollup_time, up_count, down_count, rtt_min, rtt_avg, rtt_max) SELECT "+hostIdColumn+", $2, $3, $4, $5, $6, $7, $8 FROM hosts WHERE host = $1 LIMIT 1;",
+				host, resolution, rd.Timestamp, rd.Up, rd.Down, rd.RttMin, rd.RttAvg, rd.RttMax)
+			if e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+}
+
+func scanRollupsCommon(rows *sql.Rows) (r []RollupData, err error) {
+	r = make([]RollupData, 0)
+	for rows.Next() {
+		var tmpDat RollupData
+		err = rows.Scan(&tmpDat.Timestamp, &tmpDat.Up, &tmpDat.Down, &tmpDat.RttMin, &tmpDat.RttAvg, &tmpDat.RttMax)
+		if err != nil {
+			return r, err
+		}
+		tmpDat.Timestamp = tmpDat.Timestamp.UTC()
+		r = append(r, tmpDat)
+	}
+	err = rows.Err()
+	if err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+func GetRollupsCommon(db *sql.DB, hostIdColumn string, chkReq ChecksRequest) (r []RollupData, err error) {
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT rollup_time, up_count, down_count, rtt_min, rtt_avg, rtt_max FROM checks_rollups WHERE host IN (SELECT " + hostIdColumn + " FROM hosts WHERE host = $1 LIMIT 1) AND resolution = $2 AND rollup_time >= $3 AND rollup_time <= $4 ORDER BY rollup_time;")
+	if err != nil {
+		return r, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query(chkReq.Host, chkReq.Resolution, chkReq.Start, chkReq.End)
+	if err != nil {
+		return r, err
+	}
+	defer rows.Close()
+	return scanRollupsCommon(rows)
+}
+
+// GetLastRollupCommon returns an empty RollupData if host has no rollups.
+func GetLastRollupCommon(db *sql.DB, hostIdColumn string, host string, resolution string) (r RollupData, err error) {
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT rollup_time, up_count, down_count, rtt_min, rtt_avg, rtt_max FROM checks_rollups WHERE host IN (SELECT " + hostIdColumn + " FROM hosts WHERE host = $1 LIMIT 1) AND resolution = $2 ORDER BY rollup_time DESC LIMIT 1;")
+	if err != nil {
+		return r, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query(host, resolution)
+	if err != nil {
+		return r, err
+	}
+	defer rows.Close()
+	var rData []RollupData
+	rData, err = scanRollupsCommon(rows)
+	if err != nil || len(rData) == 0 {
+		return r, err
+	}
+	return rData[0], nil
+}
+
+func DeleteOldRollupsCommon(db *sql.DB, resolution string, beforeTime time.Time) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "DELETE FROM checks_rollups WHERE resolution = $1 AND rollup_time < $2;", resolution, beforeTime)
+	})
+}
+
+func AddMaintenanceWindowCommon(db *sql.DB, m MaintenanceWindow) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "INSERT INTO maintenance_windows (host, tag, start_time, end_time, schedule, duration_seconds, description) VALUES ($1, $2, $3, $4, $5, $6, $7);",
+			m.Host, m.Tag, m.Start, m.End, m.Schedule, m.Duration, m.Description)
+	})
+}
+
+// GetMaintenanceWindowsCommon returns all maintenance windows. idColumn is
+// the expression that selects the row id.
+func GetMaintenanceWindowsCommon(db *sql.DB, idColumn string) (m []MaintenanceWindow, err error) {
+	m = make([]MaintenanceWindow, 0)
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT " + idColumn + ", host, tag, start_time, end_time, schedule, duration_seconds, description FROM maintenance_windows ORDER BY " + idColumn + ";")
+	if err != nil {
+		return m, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query()
+	if err != nil {
+		return m, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var w MaintenanceWindow
+		err = rows.Scan(&w.ID, &w.Host, &w.Tag, &w.Start, &w.End, &w.Schedule, &w.Duration, &w.Description)
+		if err != nil {
+			return m, err
+		}
+		w.Start = w.Start.UTC()
+		w.End = w.End.UTC()
+		m = append(m, w)
+	}
+	err = rows.Err()
+	if err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+func DeleteMaintenanceWindowCommon(db *sql.DB, idColumn string, id int64) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "DELETE FROM maintenance_windows WHERE "+idColumn+" = $1;", id)
+	})
+}
+
+// GetPausedHostsCommon returns all paused hosts. hostIdColumn is the
+// expression that selects the hosts row id.
+func GetPausedHostsCommon(db *sql.DB, hostIdColumn string) (p []HostPause, err error) {
+	p = make([]HostPause, 0)
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT hosts.host, hosts_paused.since, hosts_paused.resume_at FROM hosts, hosts_paused WHERE " + hostIdColumn + " = hosts_paused.host;")
+	if err != nil {
+		return p, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query()
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hp HostPause
+		var resumeAt sql.NullTime
+		err = rows.Scan(&hp.Host, &hp.Since, &resumeAt)
+		if err != nil {
+			return p, err
+		}
+		hp.Since = hp.Since.UTC()
+		if resumeAt.Valid {
+			t := resumeAt.Time.UTC()
+			hp.ResumeAt = &t
+		}
+		p = append(p, hp)
+	}
+	err = rows.Err()
+	if err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// GetPushMonitorCommon returns the push monitor of host or ErrNoHostInDB.
+// hostIdColumn is the expression that selects the hosts row id.
+func GetPushMonitorCommon(db *sql.DB, hostIdColumn string, host string) (m PushMonitor, err error) {
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT hosts.host, push_monitors.token, push_monitors.grace, push_monitors.created, push_monitors.last_ping FROM hosts, push_monitors WHERE " + hostIdColumn + " = push_monitors.host AND hosts.host = $1 LIMIT 1;")
+	if err != nil {
+		return m, err
+	}
+	defer stmt.Close()
+	err = stmt.QueryRow(host).Scan(&m.Host, &m.Token, &m.Grace, &m.Created, &m.LastPing)
+	if err == sql.ErrNoRows {
+		return m, ErrNoHostInDB
+	}
+	if err != nil {
+		return m, err
+	}
+	m.Created = m.Created.UTC()
+	m.LastPing = m.LastPing.UTC()
+	return m, nil
+}
+
+// GetPushMonitorsListCommon returns all push monitors.
+func GetPushMonitorsListCommon(db *sql.DB, hostIdColumn string) (m []PushMonitor, err error) {
+	m = make([]PushMonitor, 0)
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT hosts.host, push_monitors.token, push_monitors.grace, push_monitors.created, push_monitors.last_ping FROM hosts, push_monitors WHERE " + hostIdColumn + " = push_monitors.host;")
+	if err != nil {
+		return m, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query()
+	if err != nil {
+		return m, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pm PushMonitor
+		err = rows.Scan(&pm.Host, &pm.Token, &pm.Grace, &pm.Created, &pm.LastPing)
+		if err != nil {
+			return m, err
+		}
+		pm.Created = pm.Created.UTC()
+		pm.LastPing = pm.LastPing.UTC()
+		m = append(m, pm)
+	}
+	err = rows.Err()
+	if err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// Functions below are shared by the backends where hosts are referenced by
+// the id column (PostgreSQL and SQLite). Both accept $N placeholders.
+
+func DeleteHostCommon(db *sql.DB, newHost string) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		for _, query := range []string{
+			"DELETE FROM notifications_params WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);",
+			"DELETE FROM hosts_settings WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);",
+			"DELETE FROM hosts_meta WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);",
+			"DELETE FROM hosts_paused WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);",
+			"DELETE FROM push_monitors WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);",
+			"DELETE FROM maintenance_windows WHERE host = $1;",
+			"DELETE FROM http_assertions WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);",
+			"DELETE FROM host_states WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);",
+			"DELETE FROM incidents WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);",
+			"DELETE FROM checks_rollups WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);",
+			"DELETE FROM checks WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);",
+			"DELETE FROM hosts WHERE host = $1;",
+		} {
+			e := execStmtCommon(tx, query, newHost)
+			if e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+}
+
+func SaveCheckCommon(db *sql.DB, host string, cData ChecksData) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "INSERT INTO checks (host, check_time, rtt, up, reason, status_code, ip, maintenance) SELECT id, $2, $3, $4, $5, $6, $7, $8 FROM hosts WHERE host = $1 LIMIT 1;",
+			host, cData.Timestamp, cData.Rtt, cData.Up, cData.Reason, int64(cData.StatusCode), cData.IP, cData.Maintenance)
+	})
+}
+
+func GetChecksDataCommon(db *sql.DB, chkReq ChecksRequest) (cData []ChecksData, err error) {
+	cData = make([]ChecksData, 0)
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT check_time, rtt, up, reason, status_code, ip, maintenance FROM checks WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1) AND check_time >= $2 AND check_time <= $3;")
+	if err != nil {
+		return cData, err
+	}
+	defer stmt.Close()
+
+	var rows *sql.Rows
+	rows, err = stmt.Query(chkReq.Host, chkReq.Start, chkReq.End)
+	if err != nil {
+		return cData, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tmpDat ChecksData
+		err := rows.Scan(&tmpDat.Timestamp, &tmpDat.Rtt, &tmpDat.Up, &tmpDat.Reason, &tmpDat.StatusCode, &tmpDat.IP, &tmpDat.Maintenance)
+		if err != nil {
+			return cData, err
+		}
+		cData = append(cData, tmpDat)
+	}
+	err = rows.Err()
+	if err != nil {
+		return cData, err
+	}
+	return cData, nil
+}
+
+func GetLastCheckDataCommon(db *sql.DB, host string) (cData ChecksData, err error) {
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT check_time, rtt, up, reason, status_code, ip, maintenance FROM checks WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1) ORDER BY check_time DESC LIMIT 1;")
+	if err != nil {
+		return cData, err
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRow(host)
+	err = row.Scan(&cData.Timestamp, &cData.Rtt, &cData.Up, &cData.Reason, &cData.StatusCode, &cData.IP, &cData.Maintenance)
+	if err != nil {
+		return cData, err
+	}
+	return cData, nil
+}
+
+func GetFirstCheckDataCommon(db *sql.DB, host string) (cData ChecksData, err error) {
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT check_time, rtt, up, reason, status_code, ip, maintenance FROM checks WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1) ORDER BY check_time LIMIT 1;")
+	if err != nil {
+		return cData, err
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRow(host)
+	err = row.Scan(&cData.Timestamp, &cData.Rtt, &cData.Up, &cData.Reason, &cData.StatusCode, &cData.IP, &cData.Maintenance)
+	if err != nil {
+		return cData, err
+	}
+	return cData, nil
+}
+
+func AddHostStateChangeParamsCommon(db *sql.DB, p StateChangeParams) error {
+	headers, err := HeadersToString(p.Headers)
+	if err != nil {
+		return err
+	}
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		e := execStmtCommon(tx, "DELETE FROM notifications_params WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);", p.Host)
+		if e != nil {
+			return e
+		}
+		return execStmtCommon(tx, "INSERT INTO notifications_params (host, change_threshold, action, method, headers, body) SELECT id, $2, $3, $4, $5, $6 FROM hosts WHERE host = $1 LIMIT 1;",
+			p.Host, p.ChangeThreshold, p.Action, p.Method, headers, p.Body)
+	})
+}
+
+func GetHostStateChangeParamsCommon(db *sql.DB, host string) (p StateChangeParams, err error) {
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT change_threshold, action, method, headers, body FROM notifications_params WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);")
+	if err != nil {
+		return p, err
+	}
+	defer stmt.Close()
+
+	var headers string
+	row := stmt.QueryRow(host)
+	err = row.Scan(&p.ChangeThreshold, &p.Action, &p.Method, &headers, &p.Body)
+	p.Host = host
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrNoHostInDB
+		}
+		return p, err
+	}
+	p.Headers, err = HeadersFromString(headers)
+	if err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+func GetHostStateChangeParamsListCommon(db *sql.DB) (p []StateChangeParams, err error) {
+	p = make([]StateChangeParams, 0)
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT hosts.host, notifications_params.change_threshold, notifications_params.action, notifications_params.method, notifications_params.headers, notifications_params.body FROM hosts, notifications_params WHERE hosts.id = notifications_params.host;")
+	if err != nil {
+		return p, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query()
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s StateChangeParams
+		var headers string
+		err = rows.Scan(&s.Host, &s.ChangeThreshold, &s.Action, &s.Method, &headers, &s.Body)
+		if err != nil {
+			return p, err
+		}
+		s.Headers, err = HeadersFromString(headers)
+		if err != nil {
+			return p, err
+		}
+		p = append(p, s)
+	}
+	err = rows.Err()
+	if err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+func DeleteHostStateChangeParamsCommon(db *sql.DB, newHost string) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "DELETE FROM notifications_params WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);", newHost)
+	})
+}
+
+func SetHostSettingsCommon(db *sql.DB, s HostSettings) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		e := execStmtCommon(tx, "DELETE FROM hosts_settings WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);", s.Host)
+		if e != nil {
+			return e
+		}
+		return execStmtCommon(tx, "INSERT INTO hosts_settings (host, check_interval, timeout, retries, method) SELECT id, $2, $3, $4, $5 FROM hosts WHERE host = $1 LIMIT 1;",
+			s.Host, s.Interval, s.Timeout, int64(s.Retries), s.Method)
+	})
+}
+
+func GetHostSettingsCommon(db *sql.DB, host string) (s HostSettings, err error) {
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT check_interval, timeout, retries, method FROM hosts_settings WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);")
+	if err != nil {
+		return s, err
+	}
+	defer stmt.Close()
+
+	var retries int64
+	row := stmt.QueryRow(host)
+	err = row.Scan(&s.Interval, &s.Timeout, &retries, &s.Method)
+	s.Host = host
+	s.Retries = uint32(retries)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrNoHostInDB
+		}
+		return s, err
+	}
+	return s, nil
+}
+
+func GetHostSettingsListCommon(db *sql.DB) (s []HostSettings, err error) {
+	s = make([]HostSettings, 0)
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT hosts.host, hosts_settings.check_interval, hosts_settings.timeout, hosts_settings.retries, hosts_settings.method FROM hosts, hosts_settings WHERE hosts.id = hosts_settings.host;")
+	if err != nil {
+		return s, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query()
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hs HostSettings
+		var retries int64
+		err = rows.Scan(&hs.Host, &hs.Interval, &hs.Timeout, &retries, &hs.Method)
+		if err != nil {
+			return s, err
+		}
+		hs.Retries = uint32(retries)
+		s = append(s, hs)
+	}
+	err = rows.Err()
+	if err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+func SetHostMetaCommon(db *sql.DB, m HostMeta) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		e := execStmtCommon(tx, "DELETE FROM hosts_meta WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);", m.Host)
+		if e != nil {
+			return e
+		}
+		return execStmtCommon(tx, "INSERT INTO hosts_meta (host, name, description, host_group, tags) SELECT id, $2, $3, $4, $5 FROM hosts WHERE host = $1 LIMIT 1;",
+			m.Host, m.Name, m.Description, m.Group, TagsToString(m.Tags))
+	})
+}
+
+func GetHostMetaCommon(db *sql.DB, host string) (m HostMeta, err error) {
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT name, description, host_group, tags FROM hosts_meta WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);")
+	if err != nil {
+		return m, err
+	}
+	defer stmt.Close()
+
+	var tags string
+	row := stmt.QueryRow(host)
+	err = row.Scan(&m.Name, &m.Description, &m.Group, &tags)
+	m.Host = host
+	m.Tags = TagsFromString(tags)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrNoHostInDB
+		}
+		return m, err
+	}
+	return m, nil
+}
+
+func GetHostMetaListCommon(db *sql.DB) (m []HostMeta, err error) {
+	m = make([]HostMeta, 0)
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT hosts.host, hosts_meta.name, hosts_meta.description, hosts_meta.host_group, hosts_meta.tags FROM hosts, hosts_meta WHERE hosts.id = hosts_meta.host;")
+	if err != nil {
+		return m, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query()
+	if err != nil {
+		return m, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hm HostMeta
+		var tags string
+		err = rows.Scan(&hm.Host, &hm.Name, &hm.Description, &hm.Group, &tags)
+		if err != nil {
+			return m, err
+		}
+		hm.Tags = TagsFromString(tags)
+		m = append(m, hm)
+	}
+	err = rows.Err()
+	if err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+func PauseHostCommon(db *sql.DB, p HostPause) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		e := execStmtCommon(tx, "DELETE FROM hosts_paused WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);", p.Host)
+		if e != nil {
+			return e
+		}
+		return execStmtCommon(tx, "INSERT INTO hosts_paused (host, since, resume_at) SELECT id, $2, $3 FROM hosts WHERE host = $1 LIMIT 1;",
+			p.Host, p.Since, p.ResumeAt)
+	})
+}
+
+func ResumeHostCommon(db *sql.DB, host string) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "DELETE FROM hosts_paused WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);", host)
+	})
+}
+
+func SetPushMonitorCommon(db *sql.DB, m PushMonitor) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		e := execStmtCommon(tx, "DELETE FROM push_monitors WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);", m.Host)
+		if e != nil {
+			return e
+		}
+		return execStmtCommon(tx, "INSERT INTO push_monitors (host, token, grace, created, last_ping) SELECT id, $2, $3, $4, $5 FROM hosts WHERE host = $1 LIMIT 1;",
+			m.Host, m.Token, m.Grace, m.Created, m.LastPing)
+	})
+}
+
+func AddHostHTTPAssertionsCommon(db *sql.DB, a HTTPAssertions) error {
+	headers, err := HeadersToString(a.Headers)
+	if err != nil {
+		return err
+	}
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		e := execStmtCommon(tx, "DELETE FROM http_assertions WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);", a.Host)
+		if e != nil {
+			return e
+		}
+		return execStmtCommon(tx, "INSERT INTO http_assertions (host, status_codes, body_contains, body_regex, body_not_contains, headers) SELECT id, $2, $3, $4, $5, $6 FROM hosts WHERE host = $1 LIMIT 1;",
+			a.Host, StatusCodesToString(a.StatusCodes), a.BodyContains, a.BodyRegex, a.BodyNotContains, headers)
+	})
+}
+
+func GetHostHTTPAssertionsCommon(db *sql.DB, host string) (a HTTPAssertions, err error) {
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT status_codes, body_contains, body_regex, body_not_contains, headers FROM http_assertions WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);")
+	if err != nil {
+		return a, err
+	}
+	defer stmt.Close()
+
+	var codes, headers string
+	row := stmt.QueryRow(host)
+	err = row.Scan(&codes, &a.BodyContains, &a.BodyRegex, &a.BodyNotContains, &headers)
+	a.Host = host
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrNoHostInDB
+		}
+		return a, err
+	}
+	a.StatusCodes, err = StatusCodesFromString(codes)
+	if err != nil {
+		return a, err
+	}
+	a.Headers, err = HeadersFromString(headers)
+	return a, err
+}
+
+func GetHostHTTPAssertionsListCommon(db *sql.DB) (a []HTTPAssertions, err error) {
+	a = make([]HTTPAssertions, 0)
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT hosts.host, http_assertions.status_codes, http_assertions.body_contains, http_assertions.body_regex, http_assertions.body_not_contains, http_assertions.headers FROM hosts, http_assertions WHERE hosts.id = http_assertions.host;")
+	if err != nil {
+		return a, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query()
+	if err != nil {
+		return a, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s HTTPAssertions
+		var codes, headers string
+		err = rows.Scan(&s.Host, &codes, &s.BodyContains, &s.BodyRegex, &s.BodyNotContains, &headers)
+		if err != nil {
+			return a, err
+		}
+		s.StatusCodes, err = StatusCodesFromString(codes)
+		if err != nil {
+			return a, err
+		}
+		s.Headers, err = HeadersFromString(headers)
+		if err != nil {
+			return a, err
+		}
+		a = append(a, s)
+	}
+	err = rows.Err()
+	if err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+func DeleteHostHTTPAssertionsCommon(db *sql.DB, host string) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "DELETE FROM http_assertions WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);", host)
+	})
+}
+
+func UpdateQueuedNotificationCommon(db *sql.DB, n QueuedNotification) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "UPDATE notifications_queue SET attempts = $2, next_attempt = $3 WHERE id = $1;", n.ID, n.Attempts, n.NextAttempt)
+	})
+}
+
+func DeleteQueuedNotificationCommon(db *sql.DB, id int64) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "DELETE FROM notifications_queue WHERE id = $1;", id)
+	})
+}
+
+func SetHostStateCommon(db *sql.DB, s StateChangeData) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		e := execStmtCommon(tx, "DELETE FROM host_states WHERE host IN (SELECT id FROM hosts WHERE host = $1 LIMIT 1);", s.Host)
+		if e != nil {
+			return e
+		}
+		return execStmtCommon(tx, "INSERT INTO host_states (host, observed, state, change_count, since) SELECT id, $2, $3, $4, $5 FROM hosts WHERE host = $1 LIMIT 1;",
+			s.Host, s.LastTimeObserved, s.State, s.ChangeCount, s.Since)
+	})
+}
+
+func GetHostStatesListCommon(db *sql.DB) (s []StateChangeData, err error) {
+	s = make([]StateChangeData, 0)
+	var stmt *sql.Stmt
+	stmt, err = db.Prepare("SELECT hosts.host, host_states.observed, host_states.state, host_states.change_count, host_states.since FROM hosts, host_states WHERE hosts.id = host_states.host;")
+	if err != nil {
+		return s, err
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.Query()
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hs StateChangeData
+		err = rows.Scan(&hs.Host, &hs.LastTimeObserved, &hs.State, &hs.ChangeCount, &hs.Since)
+		if err != nil {
+			return s, err
+		}
+		hs.LastTimeObserved = hs.LastTimeObserved.UTC()
+		hs.Since = hs.Since.UTC()
+		s = append(s, hs)
+	}
+	err = rows.Err()
+	if err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+func AddIncidentCommon(db *sql.DB, i Incident) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "INSERT INTO incidents (host, start_time, reason, checks) SELECT id, $2, $3, $4 FROM hosts WHERE host = $1 LIMIT 1;",
+			i.Host, i.Start, i.Reason, i.Checks)
+	})
+}
+
+func CloseIncidentCommon(db *sql.DB, i Incident) error {
+	return execTxCommon(db, func(tx *sql.Tx) error {
+		return execStmtCommon(tx, "UPDATE incidents SET end_time = $2, checks = $3 WHERE id = $1;", i.ID, *i.End, i.Checks)
+	})
+}
@@ -0,0 +1,642 @@
+package main
+
+import (
+	"database/sql"
+	_ "modernc.org/sqlite"
+	"os"
+	"time"
+)
+
+// Times are stored as text (_time_format=sqlite) and compared as strings, so
+// every time is converted to UTC before it is passed to a query.
+type MonDBSQLite struct {
+	db *sql.DB
+}
+
+func (d *MonDBSQLite) Open(cfg Configuration) error {
+	var err error
+
+	//Empty Database selects in-memory ql DB, so SQLite always uses a file
+	var performInit bool = false
+	if _, err = os.Stat(cfg.DB.Database); os.IsNotExist(err) {
+		performInit = true
+	}
+	d.db, err = sql.Open("sqlite", "file:"+cfg.DB.Database+"?_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_pragma=busy_timeout(10000)&_pragma=synchronous(NORMAL)&_time_format=sqlite&_txlock=immediate")
+
+	if err != nil {
+		return err
+	}
+	if err = d.db.Ping(); err != nil {
+		return err
+	}
+
+	if performInit {
+		err = d.Init()
+		if err != nil {
+			return err
+		}
+	} else if migrateOnOpen {
+		_, err = d.Migrate(false)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *MonDBSQLite) Close() error {
+	err := d.db.Close()
+	return err
+}
+
+func (d *MonDBSQLite) Init() error {
+	tx, err := d.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+CREATE TABLE hosts
+(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  host text NOT NULL UNIQUE
+);
+
+CREATE TABLE checks
+(
+  host integer NOT NULL,
+  check_time DATETIME NOT NULL,
+  rtt bigint NOT NULL,
+  up boolean NOT NULL,
+  CONSTRAINT checks_pkey PRIMARY KEY (host, check_time),
+  CONSTRAINT checks_host_fkey FOREIGN KEY (host)
+      REFERENCES hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+CREATE INDEX checks_check_time_idx
+  ON checks
+  (check_time);
+
+CREATE TABLE notifications_params
+(
+  host integer NOT NULL,
+  change_threshold bigint NOT NULL,
+  action text NOT NULL,
+  CONSTRAINT notifications_params_pkey PRIMARY KEY (host),
+  CONSTRAINT notifications_params_host_fkey FOREIGN KEY (host)
+      REFERENCES hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+CREATE INDEX notifications_params_host_idx
+  ON notifications_params
+  (host);
+`)
+
+	if err != nil {
+		e := tx.Rollback()
+		if e != nil {
+			return e
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	_, err = d.Migrate(false)
+	return err
+}
+
+func (d *MonDBSQLite) SchemaVersion() (version int64, err error) {
+	return getSchemaVersionCommon(d.db, "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = $1;")
+}
+
+func (d *MonDBSQLite) Migrate(dryRun bool) (applied []Migration, err error) {
+	version, err := d.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	return migrateSQLCommon(d.db, version, sqliteMigrations, sqliteSchemaVersionTable, dryRun)
+}
+
+// sqliteAddColumn adds a column if the table does not have it, so
+// migrations can be applied again to DBs that already have some of the
+// columns.
+func sqliteAddColumn(tx *sql.Tx, table string, column string, columnType string, def string) error {
+	var n int64
+	err := tx.QueryRow("SELECT count(*) FROM pragma_table_info($1) WHERE name = $2;", table, column).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err = tx.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + columnType + " NOT NULL DEFAULT " + def + ";")
+	return err
+}
+
+const sqliteSchemaVersionTable = "CREATE TABLE IF NOT EXISTS schema_version (version bigint NOT NULL);"
+
+var sqliteMigrations = []sqlMigration{
+	{Migration{2, "Add HTTP assertions"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS http_assertions
+(
+  host integer NOT NULL,
+  status_codes text NOT NULL,
+  body_contains text NOT NULL,
+  body_regex text NOT NULL,
+  body_not_contains text NOT NULL,
+  headers text NOT NULL,
+  CONSTRAINT http_assertions_pkey PRIMARY KEY (host),
+  CONSTRAINT http_assertions_host_fkey FOREIGN KEY (host)
+      REFERENCES hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+`)
+		return err
+	}},
+	{Migration{3, "Add host settings"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS hosts_settings
+(
+  host integer NOT NULL,
+  check_interval bigint NOT NULL,
+  timeout bigint NOT NULL,
+  retries bigint NOT NULL,
+  method text NOT NULL,
+  CONSTRAINT hosts_settings_pkey PRIMARY KEY (host),
+  CONSTRAINT hosts_settings_host_fkey FOREIGN KEY (host)
+      REFERENCES hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+`)
+		return err
+	}},
+	{Migration{4, "Add failure reason, status code and IP to checks"}, func(tx *sql.Tx) error {
+		for _, c := range [][4]string{
+			{"checks", "reason", "text", "''"},
+			{"checks", "status_code", "integer", "0"},
+			{"checks", "ip", "text", "''"},
+		} {
+			err := sqliteAddColumn(tx, c[0], c[1], c[2], c[3])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{Migration{5, "Add webhook method, headers and body to notification params"}, func(tx *sql.Tx) error {
+		for _, c := range [][4]string{
+			{"notifications_params", "method", "text", "''"},
+			{"notifications_params", "headers", "text", "''"},
+			{"notifications_params", "body", "text", "''"},
+		} {
+			err := sqliteAddColumn(tx, c[0], c[1], c[2], c[3])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{Migration{6, "Add notifications queue and delivery log"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS notifications_queue
+(
+  id INTEGER NOT NULL,
+  host text NOT NULL,
+  method text NOT NULL,
+  url text NOT NULL,
+  headers text NOT NULL,
+  body text NOT NULL,
+  attempts bigint NOT NULL,
+  next_attempt DATETIME NOT NULL,
+  created DATETIME NOT NULL,
+  CONSTRAINT notifications_queue_pkey PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS notifications_log
+(
+  host text NOT NULL,
+  log_time DATETIME NOT NULL,
+  method text NOT NULL,
+  url text NOT NULL,
+  attempt bigint NOT NULL,
+  status_code integer NOT NULL,
+  error text NOT NULL,
+  delivered boolean NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS notifications_log_time_idx
+  ON notifications_log
+  (log_time);
+`)
+		return err
+	}},
+	{Migration{7, "Add host states"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS host_states
+(
+  host integer NOT NULL,
+  observed DATETIME NOT NULL,
+  state boolean NOT NULL,
+  change_count bigint NOT NULL,
+  since DATETIME NOT NULL,
+  CONSTRAINT host_states_pkey PRIMARY KEY (host),
+  CONSTRAINT host_states_host_fkey FOREIGN KEY (host)
+      REFERENCES hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+`)
+		return err
+	}},
+	{Migration{8, "Add incidents"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS incidents
+(
+  id INTEGER NOT NULL,
+  host integer NOT NULL,
+  start_time DATETIME NOT NULL,
+  end_time DATETIME,
+  reason text NOT NULL,
+  checks bigint NOT NULL,
+  CONSTRAINT incidents_pkey PRIMARY KEY (id),
+  CONSTRAINT incidents_host_fkey FOREIGN KEY (host)
+      REFERENCES hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS incidents_host_idx
+  ON incidents
+  (host, start_time);
+`)
+		return err
+	}},
+	{Migration{9, "Add check rollups"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS checks_rollups
+(
+  host integer NOT NULL,
+  resolution text NOT NULL,
+  rollup_time DATETIME NOT NULL,
+  up_count bigint NOT NULL,
+  down_count bigint NOT NULL,
+  rtt_min bigint NOT NULL,
+  rtt_avg bigint NOT NULL,
+  rtt_max bigint NOT NULL,
+  CONSTRAINT checks_rollups_pkey PRIMARY KEY (host, resolution, rollup_time),
+  CONSTRAINT checks_rollups_host_fkey FOREIGN KEY (host)
+      REFERENCES hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+`)
+		return err
+	}},
+	{Migration{10, "Add host metadata"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS hosts_meta
+(
+  host integer NOT NULL,
+  name text NOT NULL,
+  description text NOT NULL,
+  host_group text NOT NULL,
+  tags text NOT NULL,
+  CONSTRAINT hosts_meta_pkey PRIMARY KEY (host),
+  CONSTRAINT hosts_meta_host_fkey FOREIGN KEY (host)
+      REFERENCES hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+`)
+		return err
+	}},
+	{Migration{11, "Add maintenance windows"}, func(tx *sql.Tx) error {
+		for _, c := range [][4]string{
+			{"checks", "maintenance", "boolean", "false"},
+		} {
+			err := sqliteAddColumn(tx, c[0], c[1], c[2], c[3])
+			if err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS maintenance_windows
+(
+  id INTEGER NOT NULL,
+  host text NOT NULL,
+  tag text NOT NULL,
+  start_time DATETIME NOT NULL,
+  end_time DATETIME NOT NULL,
+  schedule text NOT NULL,
+  duration_seconds bigint NOT NULL,
+  description text NOT NULL,
+  CONSTRAINT maintenance_windows_pkey PRIMARY KEY (id)
+);
+`)
+		return err
+	}},
+	{Migration{12, "Add host pauses"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS hosts_paused
+(
+  host integer NOT NULL,
+  since DATETIME NOT NULL,
+  resume_at DATETIME,
+  CONSTRAINT hosts_paused_pkey PRIMARY KEY (host),
+  CONSTRAINT hosts_paused_host_fkey FOREIGN KEY (host)
+      REFERENCES hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+`)
+		return err
+	}},
+	{Migration{13, "Add push monitors"}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS push_monitors
+(
+  host integer NOT NULL,
+  token text NOT NULL,
+  grace bigint NOT NULL,
+  created DATETIME NOT NULL,
+  last_ping DATETIME NOT NULL,
+  CONSTRAINT push_monitors_pkey PRIMARY KEY (host),
+  CONSTRAINT push_monitors_token_key UNIQUE (token),
+  CONSTRAINT push_monitors_host_fkey FOREIGN KEY (host)
+      REFERENCES hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+`)
+		return err
+	}},
+}
+
+func (d *MonDBSQLite) GetHostsList() (hosts []string, err error) {
+	return GetHostsListCommon(d.db)
+}
+
+func (d *MonDBSQLite) AddHost(newHost string) error {
+	return AddHostCommon(d.db, newHost)
+}
+
+func (d *MonDBSQLite) DeleteHost(newHost string) error {
+	return DeleteHostCommon(d.db, newHost)
+}
+
+func (d *MonDBSQLite) CheckHostExists(newHost string) error {
+	return CheckHostExistsCommon(d.db, newHost)
+}
+
+func (d *MonDBSQLite) SaveCheck(host string, cData ChecksData) error {
+	cData.Timestamp = cData.Timestamp.UTC()
+	return SaveCheckCommon(d.db, host, cData)
+}
+
+// SaveChecks reuses one prepared insert for all checks of the batch. Checks
+// that are already stored are skipped, so a duplicate does not roll back the
+// whole batch.
+func (d *MonDBSQLite) SaveChecks(checks []HostCheck) error {
+	return execTxCommon(d.db, func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare("INSERT INTO checks (host, check_time, rtt, up, reason, status_code, ip, maintenance) SELECT id, $2, $3, $4, $5, $6, $7, $8 FROM hosts WHERE host = $1 LIMIT 1 ON CONFLICT DO NOTHING;")
+		if err != nil {
+			return err
+		}
+		for _, c := range checks {
+			_, err = stmt.Exec(c.Host, c.Timestamp.UTC(), c.Rtt, c.Up, c.Reason, int64(c.StatusCode), c.IP, c.Maintenance)
+			if err != nil {
+				stmt.Close()
+				return err
+			}
+		}
+		return stmt.Close()
+	})
+}
+
+func (d *MonDBSQLite) GetChecksData(chkReq ChecksRequest) (cData []ChecksData, err error) {
+	chkReq.Start = chkReq.Start.UTC()
+	chkReq.End = chkReq.End.UTC()
+	return GetChecksDataCommon(d.db, chkReq)
+}
+
+func (d *MonDBSQLite) GetLastCheckData(host string) (cData ChecksData, err error) {
+	return GetLastCheckDataCommon(d.db, host)
+}
+
+func (d *MonDBSQLite) GetFirstCheckData(host string) (cData ChecksData, err error) {
+	return GetFirstCheckDataCommon(d.db, host)
+}
+
+// SQLite has no percentile or FILTER aggregates, so stats are computed from
+// the checks.
+func (d *MonDBSQLite) GetChecksStats(host string, start time.Time, end time.Time) (s ChecksStats, err error) {
+	cData, err := d.GetChecksData(ChecksRequest{Host: host, Start: start, End: end})
+	if err != nil {
+		return s, err
+	}
+	return computeChecksStats(cData), nil
+}
+
+func (d *MonDBSQLite) GetMonthlyChecks(start time.Time, end time.Time) (m []MonthlyChecks, err error) {
+	return computeMonthlyChecks(d, start, end)
+}
+
+func (d *MonDBSQLite) DeleteOldChecks(beforeTime time.Time) error {
+	return DeleteOldChecksCommon(d.db, beforeTime.UTC())
+}
+
+func (d *MonDBSQLite) SaveRollups(host string, resolution string, r []RollupData) error {
+	utc := make([]RollupData, len(r))
+	for i, rd := range r {
+		rd.Timestamp = rd.Timestamp.UTC()
+		utc[i] = rd
+	}
+	return SaveRollupsCommon(d.db, "hosts.id", host, resolution, utc)
+}
+
+func (d *MonDBSQLite) GetRollups(chkReq ChecksRequest) (r []RollupData, err error) {
+	chkReq.Start = chkReq.Start.UTC()
+	chkReq.End = chkReq.End.UTC()
+	return GetRollupsCommon(d.db, "hosts.id", chkReq)
+}
+
+func (d *MonDBSQLite) GetLastRollup(host string, resolution string) (r RollupData, err error) {
+	return GetLastRollupCommon(d.db, "hosts.id", host, resolution)
+}
+
+func (d *MonDBSQLite) DeleteOldRollups(resolution string, beforeTime time.Time) error {
+	return DeleteOldRollupsCommon(d.db, resolution, beforeTime.UTC())
+}
+
+func (d *MonDBSQLite) AddHostStateChangeParams(p StateChangeParams) error {
+	return AddHostStateChangeParamsCommon(d.db, p)
+}
+
+func (d *MonDBSQLite) GetHostStateChangeParams(host string) (p StateChangeParams, err error) {
+	return GetHostStateChangeParamsCommon(d.db, host)
+}
+
+func (d *MonDBSQLite) GetHostStateChangeParamsList() (p []StateChangeParams, err error) {
+	return GetHostStateChangeParamsListCommon(d.db)
+}
+
+func (d *MonDBSQLite) DeleteHostStateChangeParams(newHost string) error {
+	return DeleteHostStateChangeParamsCommon(d.db, newHost)
+}
+
+func (d *MonDBSQLite) SetHostSettings(s HostSettings) error {
+	return SetHostSettingsCommon(d.db, s)
+}
+
+func (d *MonDBSQLite) GetHostSettings(host string) (s HostSettings, err error) {
+	return GetHostSettingsCommon(d.db, host)
+}
+
+func (d *MonDBSQLite) GetHostSettingsList() (s []HostSettings, err error) {
+	return GetHostSettingsListCommon(d.db)
+}
+
+func (d *MonDBSQLite) SetHostMeta(m HostMeta) error {
+	return SetHostMetaCommon(d.db, m)
+}
+
+func (d *MonDBSQLite) GetHostMeta(host string) (m HostMeta, err error) {
+	return GetHostMetaCommon(d.db, host)
+}
+
+func (d *MonDBSQLite) GetHostMetaList() (m []HostMeta, err error) {
+	return GetHostMetaListCommon(d.db)
+}
+
+func (d *MonDBSQLite) PauseHost(p HostPause) error {
+	p.Since = p.Since.UTC()
+	if p.ResumeAt != nil {
+		t := p.ResumeAt.UTC()
+		p.ResumeAt = &t
+	}
+	return PauseHostCommon(d.db, p)
+}
+
+func (d *MonDBSQLite) ResumeHost(host string) error {
+	return ResumeHostCommon(d.db, host)
+}
+
+func (d *MonDBSQLite) GetPausedHosts() (p []HostPause, err error) {
+	return GetPausedHostsCommon(d.db, "hosts.id")
+}
+
+func (d *MonDBSQLite) SetPushMonitor(m PushMonitor) error {
+	m.Created = m.Created.UTC()
+	m.LastPing = m.LastPing.UTC()
+	return SetPushMonitorCommon(d.db, m)
+}
+
+func (d *MonDBSQLite) GetPushMonitor(host string) (m PushMonitor, err error) {
+	return GetPushMonitorCommon(d.db, "hosts.id", host)
+}
+
+func (d *MonDBSQLite) GetPushMonitorsList() (m []PushMonitor, err error) {
+	return GetPushMonitorsListCommon(d.db, "hosts.id")
+}
+
+func (d *MonDBSQLite) AddHostHTTPAssertions(a HTTPAssertions) error {
+	return AddHostHTTPAssertionsCommon(d.db, a)
+}
+
+func (d *MonDBSQLite) GetHostHTTPAssertions(host string) (a HTTPAssertions, err error) {
+	return GetHostHTTPAssertionsCommon(d.db, host)
+}
+
+func (d *MonDBSQLite) GetHostHTTPAssertionsList() (a []HTTPAssertions, err error) {
+	return GetHostHTTPAssertionsListCommon(d.db)
+}
+
+func (d *MonDBSQLite) DeleteHostHTTPAssertions(host string) error {
+	return DeleteHostHTTPAssertionsCommon(d.db, host)
+}
+
+func (d *MonDBSQLite) AddQueuedNotification(n QueuedNotification) error {
+	n.NextAttempt = n.NextAttempt.UTC()
+	n.Created = n.Created.UTC()
+	return AddQueuedNotificationCommon(d.db, n)
+}
+
+func (d *MonDBSQLite) GetQueuedNotifications() (n []QueuedNotification, err error) {
+	return GetQueuedNotificationsCommon(d.db, "id")
+}
+
+func (d *MonDBSQLite) UpdateQueuedNotification(n QueuedNotification) error {
+	n.NextAttempt = n.NextAttempt.UTC()
+	n.Created = n.Created.UTC()
+	return UpdateQueuedNotificationCommon(d.db, n)
+}
+
+func (d *MonDBSQLite) DeleteQueuedNotification(id int64) error {
+	return DeleteQueuedNotificationCommon(d.db, id)
+}
+
+func (d *MonDBSQLite) AddNotificationLog(l NotificationLogEntry) error {
+	l.Time = l.Time.UTC()
+	return AddNotificationLogCommon(d.db, l)
+}
+
+func (d *MonDBSQLite) GetNotificationLog(host string, limit int) (l []NotificationLogEntry, err error) {
+	return GetNotificationLogCommon(d.db, host, limit)
+}
+
+func (d *MonDBSQLite) DeleteOldNotificationLog(beforeTime time.Time) error {
+	return DeleteOldNotificationLogCommon(d.db, beforeTime.UTC())
+}
+
+func (d *MonDBSQLite) SetHostState(s StateChangeData) error {
+	s.LastTimeObserved = s.LastTimeObserved.UTC()
+	s.Since = s.Since.UTC()
+	return SetHostStateCommon(d.db, s)
+}
+
+func (d *MonDBSQLite) GetHostStatesList() (s []StateChangeData, err error) {
+	return GetHostStatesListCommon(d.db)
+}
+
+// utcIncident converts incident times to UTC before they are stored.
+func utcIncident(i Incident) Incident {
+	i.Start = i.Start.UTC()
+	if i.End != nil {
+		t := i.End.UTC()
+		i.End = &t
+	}
+	return i
+}
+
+func (d *MonDBSQLite) AddIncident(i Incident) error {
+	return AddIncidentCommon(d.db, utcIncident(i))
+}
+
+func (d *MonDBSQLite) GetOpenIncident(host string) (i Incident, err error) {
+	return GetOpenIncidentCommon(d.db, "incidents.id", "hosts.id", host)
+}
+
+func (d *MonDBSQLite) CloseIncident(i Incident) error {
+	return CloseIncidentCommon(d.db, utcIncident(i))
+}
+
+func (d *MonDBSQLite) GetIncidents(host string, start time.Time, end time.Time) (i []Incident, err error) {
+	return GetIncidentsCommon(d.db, "incidents.id", "hosts.id", host, start.UTC(), end.UTC())
+}
+
+func (d *MonDBSQLite) AddMaintenanceWindow(m MaintenanceWindow) error {
+	m.Start = m.Start.UTC()
+	m.End = m.End.UTC()
+	return AddMaintenanceWindowCommon(d.db, m)
+}
+
+func (d *MonDBSQLite) GetMaintenanceWindows() (m []MaintenanceWindow, err error) {
+	return GetMaintenanceWindowsCommon(d.db, "id")
+}
+
+func (d *MonDBSQLite) DeleteMaintenanceWindow(id int64) error {
+	return DeleteMaintenanceWindowCommon(d.db, "id", id)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestSQLite(t *testing.T) *MonDBSQLite {
+	var cfg Configuration
+	cfg.DB.Database = filepath.Join(t.TempDir(), "test.sqlite")
+	d := &MonDBSQLite{}
+	err := d.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestSQLiteSaveChecksDuplicate(t *testing.T) {
+	d := openTestSQLite(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, h := range []string{"a.example.com", "b.example.com"} {
+		err := d.AddHost(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := d.SaveCheck("a.example.com", ChecksData{Timestamp: now, Rtt: 1000, Up: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.SaveChecks([]HostCheck{
+		{Host: "b.example.com", ChecksData: ChecksData{Timestamp: now, Rtt: 2000, Up: true}},
+		{Host: "a.example.com", ChecksData: ChecksData{Timestamp: now, Rtt: 3000, Up: false}},
+		{Host: "a.example.com", ChecksData: ChecksData{Timestamp: now.Add(time.Second), Rtt: 4000, Up: true}},
+		{Host: "b.example.com", ChecksData: ChecksData{Timestamp: now.Add(time.Second), Rtt: 5000, Up: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for h, expected := range map[string][]int64{"a.example.com": {1000, 4000}, "b.example.com": {2000, 5000}} {
+		cData, err := d.GetChecksData(ChecksRequest{Host: h, Start: now.Add(-time.Minute), End: now.Add(time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cData) != len(expected) {
+			t.Fatalf("%v: expected %d checks, got %d", h, len(expected), len(cData))
+		}
+		for i, c := range cData {
+			if c.Rtt != expected[i] {
+				t.Errorf("%v: expected rtt %d, got %d", h, expected[i], c.Rtt)
+			}
+		}
+	}
+}
+
+func TestSQLiteNonUTCRange(t *testing.T) {
+	d := openTestSQLite(t)
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	err = d.AddHost("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		err = d.SaveCheck("example.com", ChecksData{Timestamp: now.Add(time.Duration(i) * time.Minute), Rtt: 1000, Up: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = d.AddIncident(Incident{Host: "example.com", Start: now, Reason: "timeout", Checks: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Checks are stored in UTC, the range is in another zone
+	start := now.Add(-time.Hour).In(loc)
+	end := now.Add(time.Hour).In(loc)
+	cData, err := d.GetChecksData(ChecksRequest{Host: "example.com", Start: start, End: end})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cData) != 3 {
+		t.Fatalf("expected 3 checks, got %d", len(cData))
+	}
+	s, err := d.GetChecksStats("example.com", start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Checks != 3 {
+		t.Fatalf("expected stats of 3 checks, got %d", s.Checks)
+	}
+	incidents, err := d.GetIncidents("example.com", start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incidents) != 1 {
+		t.Fatalf("expected 1 incident, got %d", len(incidents))
+	}
+
+	err = d.DeleteOldChecks(now.Add(time.Minute).In(loc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cData, err = d.GetChecksData(ChecksRequest{Host: "example.com", Start: start, End: end})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cData) != 2 {
+		t.Fatalf("expected 2 checks after deleting old ones, got %d", len(cData))
+	}
+}
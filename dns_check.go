@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DnsChecker resolves a record for a name. Hosts are added as
+// dns://[resolver[:port]]/name?type=A&expect=value
+// If resolver is omitted the system resolver is used.
+type DnsChecker struct{}
+
+func init() {
+	RegisterChecker("dns", DnsChecker{})
+}
+
+var dnsRecordTypes = map[string]bool{
+	"A":     true,
+	"AAAA":  true,
+	"MX":    true,
+	"TXT":   true,
+	"CNAME": true,
+}
+
+type DnsCheckParams struct {
+	Resolver   string
+	Name       string
+	RecordType string
+	Expect     string
+}
+
+func parseDnsHost(host string) (p DnsCheckParams, err error) {
+	var u *url.URL
+	u, err = url.Parse(host)
+	if err != nil {
+		return p, err
+	}
+	if u.Host != "" {
+		if u.Port() == "" {
+			p.Resolver = net.JoinHostPort(u.Hostname(), "53")
+		} else {
+			p.Resolver = u.Host
+		}
+		if !isHostOrIP(u.Hostname()) {
+			return p, errors.New("invalid resolver")
+		}
+	}
+	p.Name = strings.Trim(u.Path, "/")
+	if p.Name == "" || !isHostOrIP(p.Name) {
+		return p, errors.New("invalid name")
+	}
+	p.RecordType = strings.ToUpper(u.Query().Get("type"))
+	if p.RecordType == "" {
+		p.RecordType = "A"
+	}
+	if !dnsRecordTypes[p.RecordType] {
+		return p, errors.New("unsupported record type")
+	}
+	p.Expect = u.Query().Get("expect")
+	return p, nil
+}
+
+func (DnsChecker) Validate(host string) bool {
+	_, err := parseDnsHost(host)
+	return err == nil
+}
+
+func (DnsChecker) Check(host string, s HostSettings) (res ChecksData, err error) {
+	var p DnsCheckParams
+	p, err = parseDnsHost(host)
+	if err != nil {
+		return res, err
+	}
+	return DnsCheck(p, s.TimeoutDuration())
+}
+
+func dnsNameEqual(a string, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
+
+func DnsCheck(p DnsCheckParams, timeout time.Duration) (res ChecksData, err error) {
+	resolver := &net.Resolver{PreferGo: true}
+	if p.Resolver != "" {
+		resolver.Dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{}
+			return d.DialContext(ctx, network, p.Resolver)
+		}
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	//Absolute name so that resolv.conf search domains are not applied
+	name := p.Name
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+
+	var answers []string
+	start := time.Now()
+	switch p.RecordType {
+	case "A", "AAAA":
+		network := "ip4"
+		if p.RecordType == "AAAA" {
+			network = "ip6"
+		}
+		var ips []net.IP
+		ips, err = resolver.LookupIP(lookupCtx, network, name)
+		for _, ip := range ips {
+			answers = append(answers, ip.String())
+		}
+	case "MX":
+		var mxs []*net.MX
+		mxs, err = resolver.LookupMX(lookupCtx, name)
+		for _, mx := range mxs {
+			answers = append(answers, mx.Host)
+		}
+	case "TXT":
+		answers, err = resolver.LookupTXT(lookupCtx, name)
+	case "CNAME":
+		var cname string
+		cname, err = resolver.LookupCNAME(lookupCtx, name)
+		if err == nil {
+			answers = append(answers, cname)
+		}
+	}
+	res.Rtt = int64(time.Since(start))
+
+	if err != nil {
+		res.Reason = err.Error()
+		if _, ok := err.(*net.DNSError); ok {
+			return res, nil
+		}
+		return res, err
+	}
+
+	if len(answers) == 0 {
+		res.Reason = "empty answer"
+		return res, nil
+	}
+	if p.RecordType == "A" || p.RecordType == "AAAA" {
+		res.IP = answers[0]
+	}
+
+	if p.Expect == "" {
+		res.Up = true
+		return res, nil
+	}
+	for _, a := range answers {
+		switch p.RecordType {
+		case "A", "AAAA":
+			ip := net.ParseIP(a)
+			if ip != nil && ip.Equal(net.ParseIP(p.Expect)) {
+				res.IP = a
+				res.Up = true
+				return res, nil
+			}
+		case "TXT":
+			if a == p.Expect {
+				res.Up = true
+				return res, nil
+			}
+		default:
+			if dnsNameEqual(a, p.Expect) {
+				res.Up = true
+				return res, nil
+			}
+		}
+	}
+	res.Reason = fmt.Sprintf("answer %v does not contain %q", answers, p.Expect)
+	return res, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// startDnsStandIn starts a UDP DNS server on a random local port. It
+// answers A, MX and TXT queries for a few names, returns NXDOMAIN for other
+// names and never answers queries for slow.test.
+func startDnsStandIn(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var req dnsmessage.Message
+			err = req.Unpack(buf[:n])
+			if err != nil || len(req.Questions) != 1 {
+				continue
+			}
+			q := req.Questions[0]
+			if q.Name.String() == "slow.test." {
+				continue
+			}
+			resp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: req.ID, Response: true, Authoritative: true, RecursionDesired: req.RecursionDesired},
+				Questions: req.Questions,
+			}
+			rh := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: dnsmessage.ClassINET, TTL: 60}
+			switch {
+			case q.Name.String() == "a.test." && q.Type == dnsmessage.TypeA:
+				resp.Answers = []dnsmessage.Resource{
+					{Header: rh, Body: &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}}},
+					{Header: rh, Body: &dnsmessage.AResource{A: [4]byte{192, 0, 2, 2}}},
+				}
+			case q.Name.String() == "mx.test." && q.Type == dnsmessage.TypeMX:
+				resp.Answers = []dnsmessage.Resource{
+					{Header: rh, Body: &dnsmessage.MXResource{Pref: 10, MX: dnsmessage.MustNewName("mail.a.test.")}},
+				}
+			case q.Name.String() == "txt.test." && q.Type == dnsmessage.TypeTXT:
+				resp.Answers = []dnsmessage.Resource{
+					{Header: rh, Body: &dnsmessage.TXTResource{TXT: []string{"v=spf1 -all"}}},
+				}
+			case strings.HasSuffix(q.Name.String(), ".test."):
+				//Known name without records of this type
+			default:
+				resp.RCode = dnsmessage.RCodeNameError
+			}
+			out, err := resp.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(out, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestDnsCheckA(t *testing.T) {
+	resolver := startDnsStandIn(t)
+	res, err := DnsCheck(DnsCheckParams{Resolver: resolver, Name: "a.test", RecordType: "A", Expect: "192.0.2.2"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Up {
+		t.Fatalf("expected up, got reason %q", res.Reason)
+	}
+	if res.IP != "192.0.2.2" {
+		t.Errorf("expected IP 192.0.2.2, got %v", res.IP)
+	}
+	if res.Rtt <= 0 {
+		t.Errorf("expected resolution time as rtt, got %v", res.Rtt)
+	}
+}
+
+func TestDnsCheckMX(t *testing.T) {
+	resolver := startDnsStandIn(t)
+	p, err := parseDnsHost("dns://" + resolver + "/mx.test?type=mx&expect=mail.a.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Resolver != resolver {
+		t.Fatalf("expected resolver %v, got %v", resolver, p.Resolver)
+	}
+	res, err := DnsCheck(p, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Up {
+		t.Fatalf("expected up, got reason %q", res.Reason)
+	}
+}
+
+func TestDnsCheckTXT(t *testing.T) {
+	resolver := startDnsStandIn(t)
+	res, err := DnsCheck(DnsCheckParams{Resolver: resolver, Name: "txt.test", RecordType: "TXT", Expect: "v=spf1 -all"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Up {
+		t.Fatalf("expected up, got reason %q", res.Reason)
+	}
+}
+
+func TestDnsCheckExpectMismatch(t *testing.T) {
+	resolver := startDnsStandIn(t)
+	res, err := DnsCheck(DnsCheckParams{Resolver: resolver, Name: "a.test", RecordType: "A", Expect: "192.0.2.9"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Up {
+		t.Fatal("expected down when the answer does not contain the expected value")
+	}
+	if !strings.Contains(res.Reason, "does not contain") {
+		t.Errorf("unexpected reason %q", res.Reason)
+	}
+}
+
+func TestDnsCheckNXDomain(t *testing.T) {
+	resolver := startDnsStandIn(t)
+	res, err := DnsCheck(DnsCheckParams{Resolver: resolver, Name: "missing.example", RecordType: "A"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Up {
+		t.Fatal("expected down for a missing name")
+	}
+}
+
+func TestDnsCheckTimeout(t *testing.T) {
+	resolver := startDnsStandIn(t)
+	start := time.Now()
+	res, err := DnsCheck(DnsCheckParams{Resolver: resolver, Name: "slow.test", RecordType: "A"}, 300*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Up {
+		t.Fatal("expected down when the resolver does not answer")
+	}
+	if len(res.Reason) == 0 {
+		t.Error("expected a reason")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("check took %v, timeout was not applied", d)
+	}
+}
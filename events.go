@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Check results and state changes are published to an event bus. Anything
+// can subscribe to it, /api/events streams events to clients as
+// Server-Sent Events.
+
+const (
+	EventCheck = "check"
+	EventState = "state"
+)
+
+type Event struct {
+	ID    int64            `json:"id"`
+	Type  string           `json:"type"`
+	Host  string           `json:"host"`
+	Check *ChecksData      `json:"check,omitempty"`
+	State *StateChangeData `json:"state,omitempty"`
+}
+
+// Last eventHistorySize events are kept, so clients that reconnect with
+// Last-Event-ID get events they have missed.
+const eventHistorySize = 1000
+const eventSubscriberBuffer = 256
+
+type EventBus struct {
+	mux         sync.Mutex
+	lastID      int64
+	history     []Event
+	subscribers map[chan Event]bool
+}
+
+var Events = &EventBus{subscribers: make(map[chan Event]bool)}
+
+// Publish sends the event to all subscribers. It never blocks: subscribers
+// that do not read events fast enough are unsubscribed and their channel is
+// closed.
+func (b *EventBus) Publish(e Event) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.lastID++
+	e.ID = b.lastID
+	if len(b.history) >= eventHistorySize {
+		b.history = append(b.history[:0], b.history[1:]...)
+	}
+	b.history = append(b.history, e)
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns a channel with new events. If after is not negative
+// kept events with greater ID are returned too.
+func (b *EventBus) Subscribe(after int64) (ch chan Event, missed []Event) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	ch = make(chan Event, eventSubscriberBuffer)
+	b.subscribers[ch] = true
+	if after < 0 {
+		return ch, nil
+	}
+	for _, e := range b.history {
+		if e.ID > after {
+			missed = append(missed, e)
+		}
+	}
+	return ch, missed
+}
+
+func (b *EventBus) Unsubscribe(ch chan Event) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.subscribers[ch] {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+func publishCheckEvent(host string, cData ChecksData) {
+	Events.Publish(Event{Type: EventCheck, Host: host, Check: &cData})
+}
+
+func publishStateEvent(s StateChangeData) {
+	Events.Publish(Event{Type: EventState, Host: s.Host, State: &s})
+}
+
+// eventFilter selects events by type, host, group and tag. Hosts matching
+// group and tag are reloaded every scheduleRefreshInterval.
+type eventFilter struct {
+	Type   string
+	Host   string
+	Hosts  HostFilter
+	hosts  map[string]bool
+	loaded time.Time
+}
+
+func (f *eventFilter) Match(e Event) bool {
+	if len(f.Type) > 0 && e.Type != f.Type {
+		return false
+	}
+	if len(f.Host) > 0 && e.Host != f.Host {
+		return false
+	}
+	if f.Hosts.IsEmpty() {
+		return true
+	}
+	if time.Since(f.loaded) >= scheduleRefreshInterval {
+		hosts, err := MonData.GetHostsList()
+		if err == nil {
+			hosts, err = filterHosts(hosts, f.Hosts)
+		}
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+		} else {
+			f.hosts = make(map[string]bool)
+			for _, h := range hosts {
+				f.hosts[h] = true
+			}
+		}
+		f.loaded = time.Now()
+	}
+	return f.hosts[e.Host]
+}
+
+const eventsKeepAliveInterval = 15 * time.Second
+
+func writeEvent(w http.ResponseWriter, e Event) error {
+	jsonData, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte("id: " + strconv.FormatInt(e.ID, 10) + "\nevent: " + e.Type + "\ndata: " + string(jsonData) + "\n\n"))
+	return err
+}
+
+const EventsHandlerEndpoint string = "/api/events"
+
+// EventsHandler streams events as Server-Sent Events. Web server closes
+// connections after WriteTimeout, so the stream is ended a bit earlier and
+// the client reconnects with Last-Event-ID.
+func EventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	f := &eventFilter{
+		Type:  r.URL.Query().Get("type"),
+		Host:  r.URL.Query().Get("host"),
+		Hosts: getHostFilter(r.URL.Query().Get),
+	}
+	if len(f.Type) > 0 && f.Type != EventCheck && f.Type != EventState {
+		http.Error(w, "Bad type", http.StatusBadRequest)
+		return
+	}
+
+	var after int64 = -1
+	lastID := r.Header.Get("Last-Event-ID")
+	if len(lastID) == 0 {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	if len(lastID) > 0 {
+		var err error
+		after, err = strconv.ParseInt(strings.TrimSpace(lastID), 10, 64)
+		if err != nil {
+			http.Error(w, "Bad Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ch, missed := Events.Subscribe(after)
+	defer Events.Unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	_, err := w.Write([]byte("retry: 1000\n\n"))
+	if err != nil {
+		return
+	}
+	for _, e := range missed {
+		if !f.Match(e) {
+			continue
+		}
+		err = writeEvent(w, e)
+		if err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	var end <-chan time.Time
+	if Config.Listen.WriteTimeout > 0 {
+		d := time.Duration(Config.Listen.WriteTimeout)*time.Second - 2*time.Second
+		if d < time.Second {
+			d = time.Second
+		}
+		end = time.After(d)
+	}
+	keepAlive := time.NewTicker(eventsKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				//Client is too slow, it will reconnect and get missed events
+				return
+			}
+			if !f.Match(e) {
+				continue
+			}
+			err = writeEvent(w, e)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			_, err = w.Write([]byte(": keepalive\n\n"))
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-end:
+			return
+		case <-r.Context().Done():
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -7,4 +7,5 @@ require (
 	github.com/lib/pq v1.10.2
 	golang.org/x/net v0.0.0-20210614182718-04defd469f4e
 	modernc.org/ql v1.3.2-0.20210627163952-a99067dc33a2
+	modernc.org/sqlite v1.20.0
 )
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HostMetaFields describe a host for people. They do not affect checks.
+type HostMetaFields struct {
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Group       string   `json:"group,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+type HostMeta struct {
+	Host string `json:"host"`
+	HostMetaFields
+}
+
+// HostDetails is a host with its settings and metadata as used by
+// /api/hosts endpoint.
+type HostDetails struct {
+	HostSettings
+	HostMetaFields
+	Paused   bool       `json:"paused,omitempty"`
+	ResumeAt *time.Time `json:"resume_at,omitempty"`
+}
+
+func (m HostMetaFields) IsEmpty() bool {
+	return m.Name == "" && m.Description == "" && m.Group == "" && len(m.Tags) == 0
+}
+
+func (m HostMetaFields) Validate() error {
+	for _, t := range m.Tags {
+		if len(t) == 0 || strings.Contains(t, ",") || strings.TrimSpace(t) != t {
+			return errors.New("Bad tag")
+		}
+	}
+	return nil
+}
+
+func (m HostMetaFields) HasTag(tag string) bool {
+	for _, t := range m.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// DisplayName returns the name of the host or the host itself if it has
+// no name.
+func (m HostMeta) DisplayName() string {
+	if len(m.Name) > 0 {
+		return m.Name
+	}
+	return m.Host
+}
+
+// normalizeTags trims, sorts and removes duplicate and empty tags.
+func normalizeTags(tags []string) []string {
+	var res []string
+	seen := make(map[string]bool)
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if len(t) == 0 || seen[t] {
+			continue
+		}
+		seen[t] = true
+		res = append(res, t)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Tags are stored as a comma separated string.
+func TagsToString(tags []string) string {
+	return strings.Join(tags, ",")
+}
+
+func TagsFromString(s string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// parseHostMeta reads metadata from form or query values. Tags are comma
+// separated.
+func parseHostMeta(get func(key string) string) (m HostMetaFields, err error) {
+	m.Name = strings.TrimSpace(get("name"))
+	m.Description = strings.TrimSpace(get("description"))
+	m.Group = strings.TrimSpace(get("group"))
+	m.Tags = normalizeTags(strings.Split(get("tags"), ","))
+	return m, m.Validate()
+}
+
+func SetHostMeta(m HostMeta) error {
+	m.Tags = normalizeTags(m.Tags)
+	err := m.Validate()
+	if err != nil {
+		return err
+	}
+	err = MonData.CheckHostExists(m.Host)
+	if err != nil {
+		return err
+	}
+	return MonData.SetHostMeta(m)
+}
+
+// getHostMeta returns stored metadata of a host or empty metadata.
+func getHostMeta(host string) HostMeta {
+	m, err := MonData.GetHostMeta(host)
+	if err != nil {
+		if err != ErrNoHostInDB {
+			log.Printf("[ERROR] %v", err)
+		}
+		m = HostMeta{Host: host}
+	}
+	return m
+}
+
+func getHostMetaMap() (map[string]HostMeta, error) {
+	meta := make(map[string]HostMeta)
+	list, err := MonData.GetHostMetaList()
+	if err != nil {
+		return meta, err
+	}
+	for _, m := range list {
+		meta[m.Host] = m
+	}
+	return meta, nil
+}
+
+// HostFilter selects hosts by group and tag. Empty fields match all hosts.
+type HostFilter struct {
+	Group string
+	Tag   string
+}
+
+func getHostFilter(get func(key string) string) HostFilter {
+	return HostFilter{Group: get("group"), Tag: get("tag")}
+}
+
+func (f HostFilter) IsEmpty() bool {
+	return f.Group == "" && f.Tag == ""
+}
+
+func (f HostFilter) Match(m HostMetaFields) bool {
+	if len(f.Group) > 0 && m.Group != f.Group {
+		return false
+	}
+	if len(f.Tag) > 0 && !m.HasTag(f.Tag) {
+		return false
+	}
+	return true
+}
+
+// filterHosts returns hosts that match the filter.
+func filterHosts(hosts []string, f HostFilter) ([]string, error) {
+	if f.IsEmpty() {
+		return hosts, nil
+	}
+	meta, err := getHostMetaMap()
+	if err != nil {
+		return hosts, err
+	}
+	res := make([]string, 0)
+	for _, h := range hosts {
+		if f.Match(meta[h].HostMetaFields) {
+			res = append(res, h)
+		}
+	}
+	return res, nil
+}
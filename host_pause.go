@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+)
+
+// HostPause stops checks of a host without removing its history. Paused
+// hosts are resumed automatically at ResumeAt if it is set.
+type HostPause struct {
+	Host     string     `json:"host"`
+	Since    time.Time  `json:"since"`
+	ResumeAt *time.Time `json:"resume_at,omitempty"`
+}
+
+// parseHostPause reads the resume time from form or query values. It can be
+// set as unix timestamp (until) or as a number of hours from now (hours).
+func parseHostPause(host string, get func(key string) string) (p HostPause, err error) {
+	p.Host = host
+	p.Since = time.Now().UTC()
+	if v := get("until"); len(v) > 0 {
+		var ts int64
+		ts, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return p, errors.New("Bad until")
+		}
+		t := time.Unix(ts, 0).UTC()
+		p.ResumeAt = &t
+	} else if v := get("hours"); len(v) > 0 {
+		var h float64
+		h, err = strconv.ParseFloat(v, 64)
+		if err != nil || h <= 0 {
+			return p, errors.New("Bad hours")
+		}
+		t := p.Since.Add(time.Duration(h * float64(time.Hour))).Truncate(time.Second)
+		p.ResumeAt = &t
+	}
+	if p.ResumeAt != nil && !p.ResumeAt.After(p.Since) {
+		return p, errors.New("Resume time is in the past")
+	}
+	return p, nil
+}
+
+func PauseHost(p HostPause) error {
+	err := MonData.CheckHostExists(p.Host)
+	if err != nil {
+		return err
+	}
+	defer reloadSchedule()
+	return MonData.PauseHost(p)
+}
+
+func ResumeHost(host string) error {
+	err := MonData.CheckHostExists(host)
+	if err != nil {
+		return err
+	}
+	defer reloadSchedule()
+	return MonData.ResumeHost(host)
+}
+
+func getPausedHostsMap() (map[string]HostPause, error) {
+	paused := make(map[string]HostPause)
+	list, err := MonData.GetPausedHosts()
+	if err != nil {
+		return paused, err
+	}
+	for _, p := range list {
+		paused[p.Host] = p
+	}
+	return paused, nil
+}
+
+// isPaused reports whether host is paused at t. Hosts with passed resume
+// time should be resumed with resumeExpiredPauses.
+func (p HostPause) isPaused(t time.Time) bool {
+	return p.ResumeAt == nil || t.Before(*p.ResumeAt)
+}
+
+// resumeExpiredPauses removes pauses with passed resume time from the DB.
+func resumeExpiredPauses(hosts []string) {
+	for _, h := range hosts {
+		err := MonData.ResumeHost(h)
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HostSettings overrides global check settings for a single host.
+// Zero values mean that the value from Config.Checks is used.
+type HostSettings struct {
+	Host     string `json:"host"`
+	Interval int64  `json:"interval,omitempty"`
+	Timeout  int64  `json:"timeout,omitempty"`
+	Retries  uint32 `json:"retries,omitempty"`
+	Method   string `json:"method,omitempty"`
+}
+
+var allowedHTTPMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"POST":    true,
+	"OPTIONS": true,
+}
+
+func (s HostSettings) IsEmpty() bool {
+	return s.Interval == 0 && s.Timeout == 0 && s.Retries == 0 && s.Method == ""
+}
+
+func (s HostSettings) Validate() error {
+	if s.Interval < 0 {
+		return errors.New("Bad interval")
+	}
+	if s.Timeout < 0 {
+		return errors.New("Bad timeout")
+	}
+	if s.Method != "" && !allowedHTTPMethods[s.Method] {
+		return errors.New("Bad method")
+	}
+	return nil
+}
+
+// Effective returns settings with global defaults in place of zero values.
+func (s HostSettings) Effective() HostSettings {
+	if s.Interval <= 0 {
+		s.Interval = Config.Checks.Interval
+	}
+	if s.Timeout <= 0 {
+		s.Timeout = Config.Checks.Timeout
+	}
+	if s.Retries < 1 {
+		s.Retries = Config.Checks.PingRetryCount
+	}
+	if s.Method == "" {
+		s.Method = Config.Checks.HTTPMethod
+	}
+	return s
+}
+
+func (s HostSettings) IntervalDuration() time.Duration {
+	return time.Duration(s.Interval) * time.Second
+}
+
+func (s HostSettings) TimeoutDuration() time.Duration {
+	return time.Duration(s.Timeout) * time.Second
+}
+
+// getHostSettings returns effective settings for a host.
+func getHostSettings(host string) HostSettings {
+	if MonData == nil {
+		return HostSettings{Host: host}.Effective()
+	}
+	s, err := MonData.GetHostSettings(host)
+	if err != nil {
+		if err != ErrNoHostInDB {
+			log.Printf("[ERROR] %v", err)
+		}
+		s = HostSettings{Host: host}
+	}
+	return s.Effective()
+}
+
+// getHostSettingsMap returns effective settings for every host in hosts.
+func getHostSettingsMap(hosts []string) (map[string]HostSettings, error) {
+	settings := make(map[string]HostSettings)
+	list, err := MonData.GetHostSettingsList()
+	if err != nil {
+		return settings, err
+	}
+	for _, s := range list {
+		settings[s.Host] = s.Effective()
+	}
+	for _, h := range hosts {
+		if _, ok := settings[h]; !ok {
+			settings[h] = HostSettings{Host: h}.Effective()
+		}
+	}
+	return settings, nil
+}
+
+// parseHostSettings reads settings from form or query values.
+func parseHostSettings(host string, get func(key string) string) (s HostSettings, err error) {
+	s.Host = host
+	if v := get("interval"); len(v) > 0 {
+		s.Interval, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return s, errors.New("Bad interval")
+		}
+	}
+	if v := get("timeout"); len(v) > 0 {
+		s.Timeout, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return s, errors.New("Bad timeout")
+		}
+	}
+	if v := get("retries"); len(v) > 0 {
+		var r uint64
+		r, err = strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return s, errors.New("Bad retries")
+		}
+		s.Retries = uint32(r)
+	}
+	s.Method = strings.ToUpper(get("method"))
+	return s, s.Validate()
+}
+
+func SetHostSettings(s HostSettings) error {
+	s.Method = strings.ToUpper(s.Method)
+	err := s.Validate()
+	if err != nil {
+		return err
+	}
+	err = MonData.CheckHostExists(s.Host)
+	if err != nil {
+		return err
+	}
+	defer reloadSchedule()
+	return MonData.SetHostSettings(s)
+}
+
+func gcd(a int64, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// getScheduleStep returns the largest step at which all hosts intervals
+// can be scheduled.
+func getScheduleStep() time.Duration {
+	step := Config.Checks.Interval
+	if MonData != nil {
+		list, err := MonData.GetHostSettingsList()
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+		}
+		for _, s := range list {
+			if s.Interval > 0 {
+				step = gcd(step, s.Interval)
+			}
+		}
+	}
+	return time.Duration(step) * time.Second
+}
@@ -24,6 +24,8 @@ const hostViewTemplateDoc string = `<!DOCTYPE html>
   <input type="button" value="&larr;" onclick="showPrev()"><input type="button" value="&rarr;" onclick="showNext()">
 </form>
 
+<p><a href="` + IncidentsTemplateHandlerEndpoint + `?host={{.}}">Incidents</a></p>
+
 <div id="chart" style="margin-top: 25px;"><img alt="Chart" src="` + ChecksChartEndpoint + `?host={{.}}"></div>
 
 <script>
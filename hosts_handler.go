@@ -10,7 +10,7 @@ import (
 var ErrHostInDB = errors.New("host already exists in DB")
 
 func AddHost(newHost string) error {
-	if getCheckType(newHost) == checkInvalid {
+	if !isValidCheckHost(newHost) {
 		return errors.New("Host not acceptable")
 	}
 	var err error
@@ -25,11 +25,124 @@ func AddHost(newHost string) error {
 	if err != nil {
 		return err
 	}
+	reloadSchedule()
+	if isPushHost(newHost) {
+		_, err = createPushMonitor(newHost)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// AddHostWithDetails adds a host and stores its settings and metadata if
+// any are set.
+func AddHostWithDetails(d HostDetails) error {
+	err := d.HostSettings.Validate()
+	if err != nil {
+		return err
+	}
+	d.Tags = normalizeTags(d.Tags)
+	err = d.HostMetaFields.Validate()
+	if err != nil {
+		return err
+	}
+	err = AddHost(d.Host)
+	if err != nil {
+		return err
+	}
+	if !d.HostSettings.IsEmpty() {
+		err = SetHostSettings(d.HostSettings)
+		if err != nil {
+			return err
+		}
+	}
+	if d.HostMetaFields.IsEmpty() {
+		return nil
+	}
+	return SetHostMeta(HostMeta{Host: d.Host, HostMetaFields: d.HostMetaFields})
+}
+
+// SetHostDetails replaces settings and metadata of a host.
+func SetHostDetails(d HostDetails) error {
+	err := SetHostSettings(d.HostSettings)
+	if err != nil {
+		return err
+	}
+	return SetHostMeta(HostMeta{Host: d.Host, HostMetaFields: d.HostMetaFields})
+}
+
+// GetHostsDetailsList returns stored settings, metadata and pause state for
+// every host that matches the filter. Hosts without settings or metadata are
+// returned with empty values.
+func GetHostsDetailsList(f HostFilter) (list []HostDetails, err error) {
+	list = make([]HostDetails, 0)
+	var hosts []string
+	hosts, err = MonData.GetHostsList()
+	if err != nil {
+		return list, err
+	}
+	var stored []HostSettings
+	stored, err = MonData.GetHostSettingsList()
+	if err != nil {
+		return list, err
+	}
+	settings := make(map[string]HostSettings)
+	for _, s := range stored {
+		settings[s.Host] = s
+	}
+	var meta map[string]HostMeta
+	meta, err = getHostMetaMap()
+	if err != nil {
+		return list, err
+	}
+	var paused map[string]HostPause
+	paused, err = getPausedHostsMap()
+	if err != nil {
+		return list, err
+	}
+	for _, h := range hosts {
+		m := meta[h]
+		if !f.Match(m.HostMetaFields) {
+			continue
+		}
+		s, ok := settings[h]
+		if !ok {
+			s = HostSettings{Host: h}
+		}
+		d := HostDetails{HostSettings: s, HostMetaFields: m.HostMetaFields}
+		if p, ok := paused[h]; ok {
+			d.Paused = true
+			d.ResumeAt = p.ResumeAt
+		}
+		list = append(list, d)
+	}
+	return list, nil
+}
+
+// parseHostDetails reads settings and metadata from form or query values.
+func parseHostDetails(host string, get func(key string) string) (d HostDetails, err error) {
+	d.HostSettings, err = parseHostSettings(host, get)
+	if err != nil {
+		return d, err
+	}
+	d.HostMetaFields, err = parseHostMeta(get)
+	return d, err
+}
+
+// parseHostBody reads a host from request body. Body can be a plain host
+// string or a json object with host settings and metadata.
+func parseHostBody(body []byte) (d HostDetails, err error) {
+	if len(body) > 0 && body[0] == '{' {
+		err = json.Unmarshal(body, &d)
+		return d, err
+	}
+	d.Host = string(body)
+	return d, nil
+}
+
 func DeleteHost(newHost string) error {
-	if getCheckType(newHost) == checkInvalid {
+	if !isValidCheckHost(newHost) {
 		return errors.New("Host not acceptable")
 	}
 	var err error
@@ -41,7 +154,12 @@ func DeleteHost(newHost string) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	reloadSchedule()
+	if isPushHost(newHost) {
+		forgetPushHost(newHost)
+	}
+	//Maintenance windows of the host are deleted with it
+	return loadMaintenanceWindows()
 }
 
 const JsonHostsHandlerEndpoint string = "/api/hosts"
@@ -51,7 +169,18 @@ func JsonHostsHandler(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		action := r.URL.Query().Get("action")
 		if len(action) == 0 {
-			hosts, err := MonData.GetHostsList()
+			var hosts interface{}
+			var err error
+			filter := getHostFilter(r.URL.Query().Get)
+			if len(r.URL.Query().Get("details")) > 0 {
+				hosts, err = GetHostsDetailsList(filter)
+			} else {
+				var list []string
+				list, err = MonData.GetHostsList()
+				if err == nil {
+					hosts, err = filterHosts(list, filter)
+				}
+			}
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
@@ -89,18 +218,61 @@ func JsonHostsHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		if action == "add" {
+		if action == "add" || action == "settings" {
 			newHost := r.URL.Query().Get("host")
 			if len(newHost) <= 0 {
 				http.Error(w, "Bad request", http.StatusBadRequest)
 				return
 			}
-			err := AddHost(newHost)
+			d, err := parseHostDetails(newHost, r.URL.Query().Get)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
 			}
-			http.Redirect(w, r, HostsTemplateHandlerEndpoint+"?action=created", http.StatusSeeOther)
+			if action == "add" {
+				err = AddHostWithDetails(d)
+			} else {
+				err = SetHostDetails(d)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if action == "add" {
+				http.Redirect(w, r, HostsTemplateHandlerEndpoint+"?action=created", http.StatusSeeOther)
+			} else {
+				http.Redirect(w, r, HostsTemplateHandlerEndpoint+"?action=updated", http.StatusSeeOther)
+			}
+			return
+		}
+
+		if action == "pause" || action == "resume" {
+			newHost := r.URL.Query().Get("host")
+			if len(newHost) <= 0 {
+				http.Error(w, "Bad request", http.StatusBadRequest)
+				return
+			}
+			var err error
+			if action == "pause" {
+				var p HostPause
+				p, err = parseHostPause(newHost, r.URL.Query().Get)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				err = PauseHost(p)
+			} else {
+				err = ResumeHost(newHost)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if action == "pause" {
+				http.Redirect(w, r, HostsTemplateHandlerEndpoint+"?action=paused", http.StatusSeeOther)
+			} else {
+				http.Redirect(w, r, HostsTemplateHandlerEndpoint+"?action=resumed", http.StatusSeeOther)
+			}
 			return
 		}
 
@@ -144,9 +316,13 @@ func JsonHostsHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Bad request", http.StatusBadRequest)
 			return
 		}
-		var newHost string = string(body)
+		newHost, err := parseHostBody(body)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
 
-		err = AddHost(newHost)
+		err = AddHostWithDetails(newHost)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -155,6 +331,44 @@ func JsonHostsHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusCreated)
 		return
 
+	case http.MethodPut:
+		if Config.Listen.WebAuth.Enable {
+			username, password, authOK := r.BasicAuth()
+			if authOK == false {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("401 - Not authorized"))
+				return
+			}
+
+			if username != Config.Listen.WebAuth.User || password != Config.Listen.WebAuth.Password {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("401 - Not authorized"))
+				return
+			}
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		var d HostDetails
+		err = json.Unmarshal(body, &d)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		err = SetHostDetails(d)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		return
+
 	case http.MethodDelete:
 		if Config.Listen.WebAuth.Enable {
 			username, password, authOK := r.BasicAuth()
@@ -4,6 +4,7 @@ import (
 	"html/template"
 	"log"
 	"net/http"
+	"time"
 )
 
 const hostsTemplateDoc string = `<!DOCTYPE html>
@@ -22,6 +23,21 @@ const hostsTemplateDoc string = `<!DOCTYPE html>
 <form action="` + HostsTemplateHandlerEndpoint + `" method="post">
   <input type="hidden" name="action" value="add">
   <input name="host" type="text">
+  Interval: <input name="interval" type="number" min="0" placeholder="{{.Defaults.Interval}}">
+  Timeout: <input name="timeout" type="number" min="0" placeholder="{{.Defaults.Timeout}}">
+  Retries: <input name="retries" type="number" min="0" placeholder="{{.Defaults.Retries}}">
+  Method: <select name="method">
+    <option value="">default ({{.Defaults.Method}})</option>
+    <option>GET</option>
+    <option>HEAD</option>
+    <option>POST</option>
+    <option>OPTIONS</option>
+  </select>
+  <br>
+  Name: <input name="name" type="text">
+  Group: <input name="group" type="text">
+  Tags: <input name="tags" type="text" placeholder="tag1,tag2">
+  Description: <input name="description" type="text">
   <input type="submit" value="Add">
 </form>
 
@@ -29,19 +45,75 @@ const hostsTemplateDoc string = `<!DOCTYPE html>
 <h2>Host created</h2>
 {{end}}
 
+{{if .Updated}}
+<h2>Host updated</h2>
+{{end}}
+
 {{if .Deleted}}
 <h2>Host deleted</h2>
 {{end}}
 
+{{if .Paused}}
+<h2>Host paused</h2>
+{{end}}
+
+{{if .Resumed}}
+<h2>Host resumed</h2>
+{{end}}
+
 <h2>Hosts</h2>
+<form action="` + HostsTemplateHandlerEndpoint + `" method="get">
+  Group: <input name="group" type="text" value="{{.Filter.Group}}">
+  Tag: <input name="tag" type="text" value="{{.Filter.Tag}}">
+  <input type="submit" value="Filter">
+  {{if not .Filter.IsEmpty}}<a href="` + HostsTemplateHandlerEndpoint + `">Show all</a>{{end}}
+</form>
 <table id="hosts">
+  <tr>
+	<th>Host</th>
+	<th>Group</th>
+	<th>Tags</th>
+	<th>Chart</th>
+	<th>Interval / Timeout / Retries / Method<br>Name / Group / Tags / Description</th>
+	<th>Pause</th>
+	<th>Delete</th>
+  </tr>
+{{$defaults := .Defaults}}
 {{range .Hosts}}
   <tr>
-	<td><a href="` + HostsViewTemplateHandlerEndpoint + `?host={{.}}">{{.}}</a></td>
-	<td><a href="` + ChecksChartEndpoint + `?host={{.}}">Last day</a></td>
+	<td><a href="` + HostsViewTemplateHandlerEndpoint + `?host={{.Host}}">{{.Host}}</a>{{if .Name}}<br>{{.Name}}{{end}}{{if .Description}}<br><small>{{.Description}}</small>{{end}}</td>
+	<td>{{if .Group}}<a href="` + HostsTemplateHandlerEndpoint + `?group={{.Group}}">{{.Group}}</a>{{end}}</td>
+	<td>{{range .Tags}}<a href="` + HostsTemplateHandlerEndpoint + `?tag={{.}}">{{.}}</a> {{end}}</td>
+	<td><a href="` + ChecksChartEndpoint + `?host={{.Host}}">Last day</a></td>
+	<td><form action="` + HostsTemplateHandlerEndpoint + `" method="post">
+	  <input type="hidden" name="action" value="settings">
+	  <input type="hidden" name="host" value="{{.Host}}">
+	  <input name="interval" type="number" min="0" size="6" placeholder="{{$defaults.Interval}}" value="{{if .Interval}}{{.Interval}}{{end}}">
+	  <input name="timeout" type="number" min="0" size="6" placeholder="{{$defaults.Timeout}}" value="{{if .Timeout}}{{.Timeout}}{{end}}">
+	  <input name="retries" type="number" min="0" size="6" placeholder="{{$defaults.Retries}}" value="{{if .Retries}}{{.Retries}}{{end}}">
+	  <input name="method" type="text" size="7" placeholder="{{$defaults.Method}}" value="{{.Method}}">
+	  <br>
+	  <input name="name" type="text" size="12" placeholder="name" value="{{.Name}}">
+	  <input name="group" type="text" size="8" placeholder="group" value="{{.Group}}">
+	  <input name="tags" type="text" size="12" placeholder="tags" value="{{join .Tags}}">
+	  <input name="description" type="text" size="16" placeholder="description" value="{{.Description}}">
+	  <input type="submit" value="Save">
+	</form></td>
+	<td><form action="` + HostsTemplateHandlerEndpoint + `" method="post">
+	  <input type="hidden" name="host" value="{{.Host}}">
+	{{if .Paused}}
+	  Paused{{if .ResumeAt}} until {{tz .ResumeAt}}{{end}}
+	  <input type="hidden" name="action" value="resume">
+	  <input type="submit" value="Resume">
+	{{else}}
+	  <input type="hidden" name="action" value="pause">
+	  <input name="hours" type="number" min="0" step="any" size="4" placeholder="hours">
+	  <input type="submit" value="Pause">
+	{{end}}
+	</form></td>
 	<td><form action="` + HostsTemplateHandlerEndpoint + `" method="post">
 	  <input type="hidden" name="action" value="del">
-	  <input type="hidden" name="host" value="{{.}}">
+	  <input type="hidden" name="host" value="{{.Host}}">
 	  <input type="submit" value="Delete">
 	</form></td>
   </tr>
@@ -53,12 +125,22 @@ const hostsTemplateDoc string = `<!DOCTYPE html>
 `
 
 type HostsPageData struct {
-	Created bool
-	Deleted bool
-	Hosts   []string
+	Created  bool
+	Updated  bool
+	Deleted  bool
+	Paused   bool
+	Resumed  bool
+	Defaults HostSettings
+	Filter   HostFilter
+	Hosts    []HostDetails
 }
 
-var hostsTemplate = template.Must(template.New("Hosts Template").Parse(hostsTemplateDoc))
+var hostsTemplate = template.Must(template.New("Hosts Template").Funcs(template.FuncMap{
+	"join": TagsToString,
+	"tz": func(t *time.Time) string {
+		return t.In(ChecksTZ).Format("2006-01-02 15:04:05 MST")
+	},
+}).Parse(hostsTemplateDoc))
 
 const HostsTemplateHandlerEndpoint string = "/web/hosts"
 
@@ -66,17 +148,31 @@ func HostsTemplateHandler(w http.ResponseWriter, r *http.Request) {
 	var err error
 
 	data := HostsPageData{
-		Created: false,
-		Deleted: false,
-		Hosts:   nil,
+		Created:  false,
+		Updated:  false,
+		Deleted:  false,
+		Paused:   false,
+		Resumed:  false,
+		Defaults: HostSettings{}.Effective(),
+		Filter:   getHostFilter(r.URL.Query().Get),
+		Hosts:    nil,
 	}
 	action := r.URL.Query().Get("action")
 	if action == "created" {
 		data.Created = true
 	}
+	if action == "updated" {
+		data.Updated = true
+	}
 	if action == "deleted" {
 		data.Deleted = true
 	}
+	if action == "paused" {
+		data.Paused = true
+	}
+	if action == "resumed" {
+		data.Resumed = true
+	}
 
 	if r.Method == http.MethodGet {
 		action := r.URL.Query().Get("action")
@@ -112,18 +208,51 @@ func HostsTemplateHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Bad request", http.StatusBadRequest)
 			return
 		}
-		if action != "add" && action != "del" {
+		if action != "add" && action != "del" && action != "settings" && action != "pause" && action != "resume" {
 			http.Error(w, "Bad request", http.StatusBadRequest)
 			return
 		}
 
-		if action == "add" {
-			err := AddHost(newHost)
+		if action == "add" || action == "settings" {
+			d, err := parseHostDetails(newHost, r.PostFormValue)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
 			}
-			data.Created = true
+			if action == "add" {
+				err = AddHostWithDetails(d)
+				data.Created = true
+			} else {
+				err = SetHostDetails(d)
+				data.Updated = true
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if action == "pause" {
+			p, err := parseHostPause(newHost, r.PostFormValue)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			err = PauseHost(p)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data.Paused = true
+		}
+
+		if action == "resume" {
+			err := ResumeHost(newHost)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data.Resumed = true
 		}
 
 		if action == "del" {
@@ -136,8 +265,8 @@ func HostsTemplateHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var hostsList []string
-	hostsList, err = MonData.GetHostsList()
+	var hostsList []HostDetails
+	hostsList, err = GetHostsDetailsList(data.Filter)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// HTTPAssertions are additional per host conditions for HTTP checks.
+// Empty fields are not checked. Header values are matched as substrings,
+// an empty value only requires the header to be present.
+type HTTPAssertions struct {
+	Host            string            `json:"host"`
+	StatusCodes     []int             `json:"status_codes,omitempty"`
+	BodyContains    string            `json:"body_contains,omitempty"`
+	BodyRegex       string            `json:"body_regex,omitempty"`
+	BodyNotContains string            `json:"body_not_contains,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+}
+
+const httpAssertionsMaxBody int64 = 1 << 20
+
+func (a *HTTPAssertions) NeedsBody() bool {
+	return len(a.BodyContains) > 0 || len(a.BodyRegex) > 0 || len(a.BodyNotContains) > 0
+}
+
+func (a *HTTPAssertions) Validate() error {
+	if len(a.BodyRegex) > 0 {
+		_, err := regexp.Compile(a.BodyRegex)
+		if err != nil {
+			return err
+		}
+	}
+	for _, c := range a.StatusCodes {
+		if c < 100 || c > 999 {
+			return fmt.Errorf("invalid status code: %v", c)
+		}
+	}
+	return nil
+}
+
+// CheckStatus reports whether the status code is accepted. Without
+// configured status codes any 2XX or 3XX response is accepted.
+func (a *HTTPAssertions) CheckStatus(statusCode int) error {
+	if a == nil || len(a.StatusCodes) == 0 {
+		if !(statusCode >= 200 && statusCode <= 399) {
+			return fmt.Errorf("Response status: %v", statusCode)
+		}
+		return nil
+	}
+	for _, c := range a.StatusCodes {
+		if c == statusCode {
+			return nil
+		}
+	}
+	return fmt.Errorf("Response status: %v not in %v", statusCode, a.StatusCodes)
+}
+
+// CheckResponse returns an error describing the first failed assertion.
+func (a *HTTPAssertions) CheckResponse(header http.Header, body []byte) error {
+	if len(a.BodyContains) > 0 && !strings.Contains(string(body), a.BodyContains) {
+		return fmt.Errorf("Response body does not contain %q", a.BodyContains)
+	}
+	if len(a.BodyRegex) > 0 {
+		re, err := regexp.Compile(a.BodyRegex)
+		if err != nil {
+			return err
+		}
+		if !re.Match(body) {
+			return fmt.Errorf("Response body does not match %q", a.BodyRegex)
+		}
+	}
+	if len(a.BodyNotContains) > 0 && strings.Contains(string(body), a.BodyNotContains) {
+		return fmt.Errorf("Response body contains %q", a.BodyNotContains)
+	}
+	for k, v := range a.Headers {
+		values, ok := header[http.CanonicalHeaderKey(k)]
+		if !ok {
+			return fmt.Errorf("Response header %v is missing", k)
+		}
+		if len(v) == 0 {
+			continue
+		}
+		var found bool = false
+		for _, hv := range values {
+			if strings.Contains(hv, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("Response header %v does not contain %q", k, v)
+		}
+	}
+	return nil
+}
+
+func StatusCodesToString(codes []int) string {
+	var s []string
+	for _, c := range codes {
+		s = append(s, strconv.Itoa(c))
+	}
+	return strings.Join(s, ",")
+}
+
+func StatusCodesFromString(s string) (codes []int, err error) {
+	for _, c := range strings.Split(s, ",") {
+		c = strings.TrimSpace(c)
+		if len(c) == 0 {
+			continue
+		}
+		var code int
+		code, err = strconv.Atoi(c)
+		if err != nil {
+			return codes, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func HeadersToString(headers map[string]string) (string, error) {
+	if len(headers) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(headers)
+	return string(b), err
+}
+
+func HeadersFromString(s string) (headers map[string]string, err error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	err = json.Unmarshal([]byte(s), &headers)
+	return headers, err
+}
+
+func AddHTTPAssertions(a HTTPAssertions) error {
+	if getCheckScheme(a.Host) != "http" && getCheckScheme(a.Host) != "https" {
+		return fmt.Errorf("Host is not an HTTP check: %v", a.Host)
+	}
+	err := a.Validate()
+	if err != nil {
+		return err
+	}
+	err = MonData.CheckHostExists(a.Host)
+	if err != nil {
+		return err
+	}
+	return MonData.AddHostHTTPAssertions(a)
+}
+
+const JsonHTTPAssertionsHandlerEndpoint string = "/api/http_assertions"
+
+func JsonHTTPAssertionsHandler(w http.ResponseWriter, r *http.Request) {
+	if Config.Listen.WebAuth.Enable {
+		username, password, authOK := r.BasicAuth()
+		if authOK == false {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("401 - Not authorized"))
+			return
+		}
+
+		if username != Config.Listen.WebAuth.User || password != Config.Listen.WebAuth.Password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("401 - Not authorized"))
+			return
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		a, err := MonData.GetHostHTTPAssertionsList()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		jsonData, err := json.Marshal(a)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, err = w.Write(jsonData)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		return
+
+	case http.MethodPost:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		var newAssertions HTTPAssertions
+		err = json.Unmarshal(body, &newAssertions)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		err = AddHTTPAssertions(newAssertions)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		return
+
+	case http.MethodDelete:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		var newHost string = string(body)
+
+		err = MonData.DeleteHostHTTPAssertions(newHost)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		return
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
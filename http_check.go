@@ -1,18 +1,43 @@
 package main
 
 import (
-	"fmt"
-	"net"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
-	"os"
-	"syscall"
 	"time"
 )
 
-func HttpCheck(targetUrl string, metod string) (rtt int64, up bool, err error) {
+type HttpChecker struct{}
+
+func init() {
+	RegisterChecker("http", HttpChecker{})
+	RegisterChecker("https", HttpChecker{})
+}
+
+func (HttpChecker) Validate(host string) bool {
+	_, err := url.ParseRequestURI(host)
+	return err == nil
+}
+
+func (HttpChecker) Check(host string, s HostSettings) (res ChecksData, err error) {
+	var a *HTTPAssertions
+	if MonData != nil {
+		var ha HTTPAssertions
+		ha, err = MonData.GetHostHTTPAssertions(host)
+		if err == nil {
+			a = &ha
+		} else if err != ErrNoHostInDB {
+			return res, err
+		}
+	}
+	return HttpCheck(host, s.Method, s.TimeoutDuration(), a)
+}
+
+func HttpCheck(targetUrl string, metod string, timeout time.Duration, a *HTTPAssertions) (res ChecksData, err error) {
 	client := &http.Client{
-		Timeout: time.Duration(Config.Checks.Timeout) * time.Second,
+		Timeout: timeout,
 		Transport: &http.Transport{
 			DisableKeepAlives: true,
 			//TLSClientConfig: &tls.Config{
@@ -24,62 +49,64 @@ func HttpCheck(targetUrl string, metod string) (rtt int64, up bool, err error) {
 	var req *http.Request
 	req, err = http.NewRequest(metod, targetUrl, nil)
 	if err != nil {
-		return rtt, false, err
+		return res, err
+	}
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			res.IP = addrIP(info.Conn.RemoteAddr())
+		},
 	}
-	req = req.WithContext(ctx)
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
 
 	start := time.Now()
 	var resp *http.Response
 	resp, err = client.Do(req)
 
-	rtt = int64(time.Since(start))
+	res.Rtt = int64(time.Since(start))
 
 	if err != nil {
-		switch err := err.(type) {
-		case *net.OpError:
-			if err.Timeout() {
-				return rtt, false, nil
-			}
-			if sysErr, ok := err.Err.(*os.SyscallError); ok {
-				if errno, ok := sysErr.Err.(syscall.Errno); ok {
-					if errno == syscall.ECONNABORTED || errno == syscall.ECONNRESET || errno == syscall.ECONNREFUSED || errno == syscall.ENETUNREACH || errno == syscall.EHOSTUNREACH || errno == syscall.EHOSTDOWN ||
-						errno == syscall.Errno(10053) || errno == syscall.Errno(10054) || errno == syscall.Errno(10061) || errno == syscall.Errno(10060) {
-						return rtt, false, nil
-					}
-				}
-			}
-		case *url.Error:
-			if err, ok := err.Err.(net.Error); ok && err.Timeout() {
-				return rtt, false, nil
-			}
-			if opErr, ok := err.Err.(*net.OpError); ok {
-				if sysErr, ok := opErr.Err.(*os.SyscallError); ok {
-					if errno, ok := sysErr.Err.(syscall.Errno); ok {
-						if errno == syscall.ECONNABORTED || errno == syscall.ECONNRESET || errno == syscall.ECONNREFUSED || errno == syscall.ENETUNREACH || errno == syscall.EHOSTUNREACH || errno == syscall.EHOSTDOWN ||
-							errno == syscall.Errno(10053) || errno == syscall.Errno(10054) || errno == syscall.Errno(10061) || errno == syscall.Errno(10060) {
-							return rtt, false, nil
-						}
-					}
-				}
-			}
-		case net.Error:
-			if err.Timeout() {
-				return rtt, false, nil
-			}
+		res.Reason = err.Error()
+		if isUnreachableError(err) {
+			return res, nil
 		}
-		return rtt, false, err
+		return res, err
 	}
+	res.StatusCode = resp.StatusCode
 
-	if resp != nil {
-		err = resp.Body.Close()
+	var body []byte
+	if a != nil && a.NeedsBody() {
+		body, err = ioutil.ReadAll(io.LimitReader(resp.Body, httpAssertionsMaxBody))
 		if err != nil {
-			return rtt, false, err
+			resp.Body.Close()
+			return res, err
 		}
 	}
 
-	if !(resp.StatusCode >= 200 && resp.StatusCode <= 399) {
-		return rtt, false, fmt.Errorf("Response status: %v", resp.StatusCode)
+	err = resp.Body.Close()
+	if err != nil {
+		return res, err
+	}
+
+	err = a.CheckStatus(resp.StatusCode)
+	if err != nil {
+		return res, err
+	}
+
+	if Config.Checks.CheckHTTPSCerts && resp.TLS != nil {
+		info := checkCertificate(targetUrl, resp.Request.URL.Hostname(), *resp.TLS)
+		if !info.Valid {
+			res.Reason = info.Error
+			return res, nil
+		}
+	}
+
+	if a != nil {
+		err = a.CheckResponse(resp.Header, body)
+		if err != nil {
+			return res, err
+		}
 	}
 
-	return rtt, true, nil
+	res.Up = true
+	return res, nil
 }
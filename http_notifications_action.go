@@ -1,22 +1,31 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 )
 
-func PrepareEventAction(host string, rtt int64, checkTime time.Time, up bool, action string) string {
-	action = strings.ReplaceAll(action, "{HOST}", url.QueryEscape(host))
-	action = strings.ReplaceAll(action, "{TIME}", url.QueryEscape(checkTime.In(ChecksTZ).String()))
-	action = strings.ReplaceAll(action, "{TIMESTAMP}", strconv.FormatInt(checkTime.Unix(), 10))
-	action = strings.ReplaceAll(action, "{RTT}", strconv.FormatInt(rtt, 10))
-	var rttstr time.Duration = time.Duration(rtt) * time.Nanosecond
+func PrepareEventAction(meta HostMeta, cData ChecksData, action string) string {
+	action = strings.ReplaceAll(action, "{HOST}", url.QueryEscape(meta.Host))
+	action = strings.ReplaceAll(action, "{NAME}", url.QueryEscape(meta.DisplayName()))
+	action = strings.ReplaceAll(action, "{GROUP}", url.QueryEscape(meta.Group))
+	action = strings.ReplaceAll(action, "{TIME}", url.QueryEscape(cData.Timestamp.In(ChecksTZ).String()))
+	action = strings.ReplaceAll(action, "{TIMESTAMP}", strconv.FormatInt(cData.Timestamp.Unix(), 10))
+	action = strings.ReplaceAll(action, "{RTT}", strconv.FormatInt(cData.Rtt, 10))
+	var rttstr time.Duration = time.Duration(cData.Rtt) * time.Nanosecond
 	action = strings.ReplaceAll(action, "{RTTSTR}", url.QueryEscape(rttstr.String()))
-	if up {
+	action = strings.ReplaceAll(action, "{REASON}", url.QueryEscape(cData.Reason))
+	action = strings.ReplaceAll(action, "{STATUSCODE}", strconv.Itoa(cData.StatusCode))
+	action = strings.ReplaceAll(action, "{IP}", url.QueryEscape(cData.IP))
+	if cData.Up {
 		action = strings.ReplaceAll(action, "{STATE}", "up")
 		action = strings.ReplaceAll(action, "{UP}", "true")
 		action = strings.ReplaceAll(action, "{DOWN}", "false")
@@ -28,10 +37,142 @@ func PrepareEventAction(host string, rtt int64, checkTime time.Time, up bool, ac
 	return action
 }
 
-func EventHTTPNotify(host string, rtt int64, checkTime time.Time, up bool, action string) error {
-	action = PrepareEventAction(host, rtt, checkTime, up, action)
-	fmt.Println(action)
+var allowedNotificationMethods = map[string]bool{
+	"GET":   true,
+	"POST":  true,
+	"PUT":   true,
+	"PATCH": true,
+}
+
+// NotificationEvent is passed to notification body templates. String
+// fields are escaped so that they can be placed inside JSON strings.
+type NotificationEvent struct {
+	Host                  string
+	Name                  string
+	Description           string
+	Group                 string
+	Tags                  string
+	State                 string
+	Up                    bool
+	Rtt                   int64
+	RttStr                string
+	Time                  string
+	Timestamp             int64
+	Reason                string
+	StatusCode            int
+	IP                    string
+	PreviousState         string
+	PreviousStateDuration string
+	PreviousStateSeconds  int64
+}
+
+func jsonEscapeString(s string) string {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return ""
+	}
+	return string(b[1 : len(b)-1])
+}
+
+func getNotificationEvent(meta HostMeta, cData ChecksData, prevDuration time.Duration) NotificationEvent {
+	e := NotificationEvent{
+		Host:                  jsonEscapeString(meta.Host),
+		Name:                  jsonEscapeString(meta.DisplayName()),
+		Description:           jsonEscapeString(meta.Description),
+		Group:                 jsonEscapeString(meta.Group),
+		Tags:                  jsonEscapeString(TagsToString(meta.Tags)),
+		State:                 "down",
+		Up:                    cData.Up,
+		Rtt:                   cData.Rtt,
+		RttStr:                jsonEscapeString(time.Duration(cData.Rtt).String()),
+		Time:                  jsonEscapeString(cData.Timestamp.In(ChecksTZ).String()),
+		Timestamp:             cData.Timestamp.Unix(),
+		Reason:                jsonEscapeString(cData.Reason),
+		StatusCode:            cData.StatusCode,
+		IP:                    jsonEscapeString(cData.IP),
+		PreviousState:         "up",
+		PreviousStateDuration: jsonEscapeString(prevDuration.Truncate(time.Second).String()),
+		PreviousStateSeconds:  int64(prevDuration.Seconds()),
+	}
+	if cData.Up {
+		e.State = "up"
+		e.PreviousState = "down"
+	}
+	return e
+}
+
+func parseNotificationBody(body string) (*template.Template, error) {
+	return template.New("notification").Option("missingkey=error").Parse(body)
+}
+
+// ValidateStateChangeParams checks the webhook settings and sets the default
+// method.
+func ValidateStateChangeParams(p *StateChangeParams) error {
+	p.Method = strings.ToUpper(p.Method)
+	if p.Method == "" {
+		if len(p.Body) > 0 {
+			p.Method = "POST"
+		} else {
+			p.Method = "GET"
+		}
+	}
+	if !allowedNotificationMethods[p.Method] {
+		return fmt.Errorf("Bad method: %v", p.Method)
+	}
+	if len(p.Body) > 0 {
+		_, err := parseNotificationBody(p.Body)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func AddStateChangeParams(p StateChangeParams) error {
+	err := ValidateStateChangeParams(&p)
+	if err != nil {
+		return err
+	}
+	return MonData.AddHostStateChangeParams(p)
+}
 
+// PrepareEventNotification renders the request that reports a state change.
+func PrepareEventNotification(host string, cData ChecksData, p StateChangeParams, prevDuration time.Duration) (n QueuedNotification, err error) {
+	n.Host = host
+	meta := getHostMeta(host)
+	n.URL = PrepareEventAction(meta, cData, p.Action)
+	n.Headers = make(map[string]string)
+	if len(p.Body) > 0 {
+		var tmpl *template.Template
+		tmpl, err = parseNotificationBody(p.Body)
+		if err != nil {
+			return n, err
+		}
+		var buf bytes.Buffer
+		err = tmpl.Execute(&buf, getNotificationEvent(meta, cData, prevDuration))
+		if err != nil {
+			return n, err
+		}
+		n.Body = buf.String()
+		n.Headers["Content-Type"] = "application/json"
+	}
+	for k, v := range p.Headers {
+		n.Headers[k] = v
+	}
+
+	n.Method = p.Method
+	if n.Method == "" {
+		n.Method = "GET"
+		if len(n.Body) > 0 {
+			n.Method = "POST"
+		}
+	}
+	return n, nil
+}
+
+// SendNotification performs the notification request. Status code is zero
+// if no response was received.
+func SendNotification(n QueuedNotification) (statusCode int, err error) {
 	client := &http.Client{
 		Timeout: time.Duration(30) * time.Second,
 		Transport: &http.Transport{
@@ -39,27 +180,35 @@ func EventHTTPNotify(host string, rtt int64, checkTime time.Time, up bool, actio
 		},
 	}
 
-	var err error
+	var body io.Reader
+	if len(n.Body) > 0 {
+		body = strings.NewReader(n.Body)
+	}
+
 	var req *http.Request
-	req, err = http.NewRequest("GET", action, nil)
+	req, err = http.NewRequest(n.Method, n.URL, body)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	req = req.WithContext(ctx)
+	for k, v := range n.Headers {
+		req.Header.Set(k, v)
+	}
 
 	var resp *http.Response
 	resp, err = client.Do(req)
+	if err != nil {
+		return 0, err
+	}
 
-	if resp != nil {
-		err = resp.Body.Close()
-		if err != nil {
-			return err
-		}
+	err = resp.Body.Close()
+	if err != nil {
+		return resp.StatusCode, err
 	}
 
 	if !(resp.StatusCode >= 200 && resp.StatusCode <= 399) {
-		return fmt.Errorf("Response status: %v", resp.StatusCode)
+		return resp.StatusCode, fmt.Errorf("Response status: %v", resp.StatusCode)
 	}
 
-	return nil
+	return resp.StatusCode, nil
 }
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Incident is an outage of a host. It is opened when the host state changes
+// to down and closed when the host is back up. End is nil while the incident
+// is ongoing. Start is the time of the first failed check.
+type Incident struct {
+	ID       int64      `json:"id"`
+	Host     string     `json:"host"`
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end,omitempty"`
+	Duration int64      `json:"duration"`
+	Reason   string     `json:"reason,omitempty"`
+	Checks   int64      `json:"checks"`
+}
+
+var ErrNoIncident = errors.New("no open incident")
+
+// setDuration updates Duration (in seconds). Ongoing incidents last until now.
+func (i *Incident) setDuration() {
+	end := time.Now().UTC()
+	if i.End != nil {
+		end = *i.End
+	}
+	i.Duration = int64(end.Sub(i.Start).Seconds())
+}
+
+func sortChecksData(cData []ChecksData) {
+	sort.Slice(cData, func(i, j int) bool {
+		return cData[i].Timestamp.Before(cData[j].Timestamp)
+	})
+}
+
+// openIncident records an outage that was detected by cData. The stored
+// checks before it are used to find the first failed check.
+func openIncident(host string, cData ChecksData, threshold int64) {
+	i := Incident{Host: host, Start: cData.Timestamp, Reason: cData.Reason, Checks: 1}
+
+	//Failed checks before the threshold was reached can not be older than this
+	lookBack := time.Duration(threshold+1) * getHostSettings(host).IntervalDuration()
+	checks, err := MonData.GetChecksData(ChecksRequest{Host: host, Start: cData.Timestamp.Add(-lookBack), End: cData.Timestamp})
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+	sortChecksData(checks)
+	for n := len(checks) - 1; n >= 0; n-- {
+		c := checks[n]
+		if c.Timestamp.Equal(cData.Timestamp) {
+			continue
+		}
+		if c.Up {
+			break
+		}
+		i.Start = c.Timestamp
+		i.Reason = c.Reason
+		i.Checks++
+	}
+
+	err = MonData.AddIncident(i)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+}
+
+// closeIncident closes the ongoing incident of host at the time of the first
+// successful check.
+func closeIncident(host string, cData ChecksData) {
+	i, err := MonData.GetOpenIncident(host)
+	if err != nil {
+		if err != ErrNoIncident {
+			log.Printf("[ERROR] %v", err)
+		}
+		return
+	}
+	end := cData.Timestamp
+	i.End = &end
+
+	checks, err := MonData.GetChecksData(ChecksRequest{Host: host, Start: i.Start, End: end})
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+	} else {
+		var failed int64 = 0
+		for _, c := range checks {
+			if !c.Up && c.Timestamp.Before(end) {
+				failed++
+			}
+		}
+		if failed > i.Checks {
+			i.Checks = failed
+		}
+	}
+
+	err = MonData.CloseIncident(i)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+}
+
+// getIncidents returns incidents that overlap the requested period sorted
+// from the newest.
+func getIncidents(req ChecksRequest) ([]Incident, error) {
+	incidents, err := MonData.GetIncidents(req.Host, req.Start, req.End)
+	if err != nil {
+		return incidents, err
+	}
+	for n := range incidents {
+		incidents[n].setDuration()
+	}
+	sort.Slice(incidents, func(i, j int) bool {
+		return incidents[i].Start.After(incidents[j].Start)
+	})
+	return incidents, nil
+}
+
+// getIncidentsRequest reads host, start and end query parameters. Host is
+// optional. Default period is 30 days until now.
+func getIncidentsRequest(r *http.Request) (req ChecksRequest, err error) {
+	req.Host = r.URL.Query().Get("host")
+	req.End = time.Now().UTC()
+	if endT := r.URL.Query().Get("end"); len(endT) > 0 {
+		var endTime int64
+		endTime, err = strconv.ParseInt(endT, 10, 64)
+		if err != nil {
+			return req, errors.New("Bad request")
+		}
+		req.End = time.Unix(endTime, 0).UTC()
+	}
+	req.Start = req.End.Add(-30 * 24 * time.Hour)
+	if startT := r.URL.Query().Get("start"); len(startT) > 0 {
+		var startTime int64
+		startTime, err = strconv.ParseInt(startT, 10, 64)
+		if err != nil {
+			return req, errors.New("Bad request")
+		}
+		req.Start = time.Unix(startTime, 0).UTC()
+	}
+	if req.End.Before(req.Start) {
+		return req, errors.New("Bad dates in request")
+	}
+	return req, nil
+}
+
+const JsonIncidentsHandlerEndpoint string = "/api/incidents"
+
+func JsonIncidentsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := getIncidentsRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	incidents, err := getIncidents(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonData, err := json.Marshal(incidents)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(jsonData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+const incidentsTemplateDoc string = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="UTF-8">
+  <title>Incidents</title>
+  <style>
+    td {padding-right: 1em;}
+  </style>
+</head>
+
+<body>
+
+<h2>Incidents{{if .Host}} of {{.Host}}{{end}}</h2>
+<p>{{.Start}} - {{.End}}</p>
+<table id="incidents">
+  <tr>
+	<th>Host</th>
+	<th>Start</th>
+	<th>End</th>
+	<th>Duration</th>
+	<th>Failed checks</th>
+	<th>Reason</th>
+  </tr>
+{{range .Incidents}}
+  <tr>
+	<td><a href="` + HostsViewTemplateHandlerEndpoint + `?host={{.Host}}">{{.Host}}</a></td>
+	<td>{{.Start}}</td>
+	<td>{{if .End}}{{.End}}{{else}}ongoing{{end}}</td>
+	<td>{{duration .Duration}}</td>
+	<td>{{.Checks}}</td>
+	<td>{{.Reason}}</td>
+  </tr>
+{{end}}
+</table>
+
+</body>
+</html>
+`
+
+type IncidentsPageData struct {
+	Host      string
+	Start     time.Time
+	End       time.Time
+	Incidents []Incident
+}
+
+var incidentsTemplate = template.Must(template.New("Incidents Template").Funcs(template.FuncMap{
+	"duration": func(s int64) string {
+		return (time.Duration(s) * time.Second).String()
+	},
+}).Parse(incidentsTemplateDoc))
+
+const IncidentsTemplateHandlerEndpoint string = "/web/incidents"
+
+func IncidentsTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := getIncidentsRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data := IncidentsPageData{
+		Host:  req.Host,
+		Start: req.Start.In(ChecksTZ),
+		End:   req.End.In(ChecksTZ),
+	}
+	data.Incidents, err = getIncidents(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for n := range data.Incidents {
+		data.Incidents[n].Start = data.Incidents[n].Start.In(ChecksTZ)
+		if data.Incidents[n].End != nil {
+			end := data.Incidents[n].End.In(ChecksTZ)
+			data.Incidents[n].End = &end
+		}
+	}
+
+	err = incidentsTemplate.Execute(w, data)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+}
@@ -33,6 +33,9 @@ const indexTemplateDoc string = `<!DOCTYPE html>
 <b>down</b>
 {{end}}
  rtt: {{.Rtt}}
+{{if .Reason}} reason: {{.Reason}}{{end}}
+{{if .StatusCode}} status code: {{.StatusCode}}{{end}}
+{{if .IP}} ip: {{.IP}}{{end}}
 </p>
 {{end}}
 
@@ -42,6 +45,8 @@ const indexTemplateDoc string = `<!DOCTYPE html>
   <li><a href="` + StateChangeParamsHandlerEndpoint + `">` + StateChangeParamsHandlerEndpoint + `</a></li>
   <li><a href="` + ChecksTemplateHandlerEndpoint + `">` + ChecksTemplateHandlerEndpoint + `</a></li>
   <li><a href="` + HostsViewTemplateHandlerEndpoint + `">` + HostsViewTemplateHandlerEndpoint + `</a></li>
+  <li><a href="` + IncidentsTemplateHandlerEndpoint + `">` + IncidentsTemplateHandlerEndpoint + `</a></li>
+  <li><a href="` + NotificationsLogTemplateHandlerEndpoint + `">` + NotificationsLogTemplateHandlerEndpoint + `</a></li>
 </ul>
 
 <h2>API Endpoints</h2>
@@ -52,10 +57,18 @@ const indexTemplateDoc string = `<!DOCTYPE html>
   <li><a href="` + JsonBackupFullHandlerEndpoint + `">` + JsonBackupFullHandlerEndpoint + `</a></li>
   <li><a href="` + JsonChecksHandlerEndpoint + `">` + JsonChecksHandlerEndpoint + `</a></li>
   <li><a href="` + JsonChecksLastHandlerEndpoint + `">` + JsonChecksLastHandlerEndpoint + `</a></li>
+  <li><a href="` + JsonStatesHandlerEndpoint + `">` + JsonStatesHandlerEndpoint + `</a></li>
+  <li><a href="` + EventsHandlerEndpoint + `">` + EventsHandlerEndpoint + `</a></li>
+  <li><a href="` + JsonIncidentsHandlerEndpoint + `">` + JsonIncidentsHandlerEndpoint + `</a></li>
+  <li><a href="` + JsonSLAHandlerEndpoint + `">` + JsonSLAHandlerEndpoint + `</a></li>
+  <li><a href="` + JsonCertificatesHandlerEndpoint + `">` + JsonCertificatesHandlerEndpoint + `</a></li>
+  <li><a href="` + JsonHTTPAssertionsHandlerEndpoint + `">` + JsonHTTPAssertionsHandlerEndpoint + `</a></li>
+  <li><a href="` + JsonNotificationsLogHandlerEndpoint + `">` + JsonNotificationsLogHandlerEndpoint + `</a></li>
 {{if .AllowSingleChecks}}
   <li><a href="` + JsonCheckHandlerEndpoint + `">` + JsonCheckHandlerEndpoint + `</a></li>
 {{end}}
   <li><a href="` + ChecksChartEndpoint + `">` + ChecksChartEndpoint + `</a></li>
+  <li><a href="` + MetricsHandlerEndpoint + `">` + MetricsHandlerEndpoint + `</a></li>
 </ul>
 
 </body>
@@ -71,20 +84,22 @@ type IndexPageData struct {
 	Check             bool
 	Up                bool
 	Rtt               time.Duration
+	Reason            string
+	StatusCode        int
+	IP                string
 }
 
 var indexTemplate = template.Must(template.New("Index Template").Parse(indexTemplateDoc))
 
 func IndexTemplateHandler(w http.ResponseWriter, r *http.Request) {
-	var rtt time.Duration
-	var up bool = false
+	var cData ChecksData
 	var sCheck = false
 	var err error
 	var chkHost string
 	if Config.Checks.AllowSingleChecks {
 		chkHost = r.URL.Query().Get("host")
 		if len(chkHost) > 0 {
-			up, rtt, err = doSingleCheck(chkHost)
+			cData, err = doSingleCheck(chkHost)
 			if err == nil {
 				sCheck = true
 			}
@@ -94,8 +109,11 @@ func IndexTemplateHandler(w http.ResponseWriter, r *http.Request) {
 		AllowSingleChecks: Config.Checks.AllowSingleChecks,
 		Host:              chkHost,
 		Check:             sCheck,
-		Up:                up,
-		Rtt:               rtt,
+		Up:                cData.Up,
+		Rtt:               time.Duration(cData.Rtt),
+		Reason:            cData.Reason,
+		StatusCode:        cData.StatusCode,
+		IP:                cData.IP,
 	}
 
 	err = indexTemplate.Execute(w, data)
@@ -12,6 +12,10 @@ CREATE TABLE public.checks
   check_time timestamp without time zone NOT NULL,
   rtt bigint NOT NULL,
   up boolean NOT NULL,
+  reason text NOT NULL DEFAULT '',
+  status_code integer NOT NULL DEFAULT 0,
+  ip text NOT NULL DEFAULT '',
+  maintenance boolean NOT NULL DEFAULT false,
   CONSTRAINT checks_pkey PRIMARY KEY (host, check_time),
   CONSTRAINT checks_host_fkey FOREIGN KEY (host)
       REFERENCES public.hosts (id) MATCH SIMPLE
@@ -28,6 +32,9 @@ CREATE TABLE public.notifications_params
   host integer NOT NULL,
   change_threshold bigint NOT NULL,
   action text NOT NULL,
+  method text NOT NULL DEFAULT '',
+  headers text NOT NULL DEFAULT '',
+  body text NOT NULL DEFAULT '',
   CONSTRAINT notifications_params_pkey PRIMARY KEY (host),
   CONSTRAINT notifications_params_host_fkey FOREIGN KEY (host)
       REFERENCES public.hosts (id) MATCH SIMPLE
@@ -38,3 +45,167 @@ CREATE INDEX notifications_params_host_idx
   ON public.notifications_params
   USING brin
   (host);
+
+CREATE TABLE public.hosts_settings
+(
+  host integer NOT NULL,
+  check_interval bigint NOT NULL,
+  timeout bigint NOT NULL,
+  retries bigint NOT NULL,
+  method text NOT NULL,
+  CONSTRAINT hosts_settings_pkey PRIMARY KEY (host),
+  CONSTRAINT hosts_settings_host_fkey FOREIGN KEY (host)
+      REFERENCES public.hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+CREATE TABLE public.hosts_meta
+(
+  host integer NOT NULL,
+  name text NOT NULL,
+  description text NOT NULL,
+  host_group text NOT NULL,
+  tags text NOT NULL,
+  CONSTRAINT hosts_meta_pkey PRIMARY KEY (host),
+  CONSTRAINT hosts_meta_host_fkey FOREIGN KEY (host)
+      REFERENCES public.hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+CREATE TABLE public.hosts_paused
+(
+  host integer NOT NULL,
+  since timestamp without time zone NOT NULL,
+  resume_at timestamp without time zone,
+  CONSTRAINT hosts_paused_pkey PRIMARY KEY (host),
+  CONSTRAINT hosts_paused_host_fkey FOREIGN KEY (host)
+      REFERENCES public.hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+CREATE TABLE public.push_monitors
+(
+  host integer NOT NULL,
+  token text NOT NULL,
+  grace bigint NOT NULL,
+  created timestamp without time zone NOT NULL,
+  last_ping timestamp without time zone NOT NULL,
+  CONSTRAINT push_monitors_pkey PRIMARY KEY (host),
+  CONSTRAINT push_monitors_token_key UNIQUE (token),
+  CONSTRAINT push_monitors_host_fkey FOREIGN KEY (host)
+      REFERENCES public.hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+CREATE TABLE public.http_assertions
+(
+  host integer NOT NULL,
+  status_codes text NOT NULL,
+  body_contains text NOT NULL,
+  body_regex text NOT NULL,
+  body_not_contains text NOT NULL,
+  headers text NOT NULL,
+  CONSTRAINT http_assertions_pkey PRIMARY KEY (host),
+  CONSTRAINT http_assertions_host_fkey FOREIGN KEY (host)
+      REFERENCES public.hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+CREATE TABLE public.notifications_queue
+(
+  id SERIAL NOT NULL,
+  host text NOT NULL,
+  method text NOT NULL,
+  url text NOT NULL,
+  headers text NOT NULL,
+  body text NOT NULL,
+  attempts bigint NOT NULL,
+  next_attempt timestamp without time zone NOT NULL,
+  created timestamp without time zone NOT NULL,
+  CONSTRAINT notifications_queue_pkey PRIMARY KEY (id)
+);
+
+CREATE TABLE public.notifications_log
+(
+  host text NOT NULL,
+  log_time timestamp without time zone NOT NULL,
+  method text NOT NULL,
+  url text NOT NULL,
+  attempt bigint NOT NULL,
+  status_code integer NOT NULL,
+  error text NOT NULL,
+  delivered boolean NOT NULL
+);
+
+CREATE INDEX notifications_log_time_idx
+  ON public.notifications_log
+  USING btree
+  (log_time);
+
+CREATE TABLE public.host_states
+(
+  host integer NOT NULL,
+  observed timestamp without time zone NOT NULL,
+  state boolean NOT NULL,
+  change_count bigint NOT NULL,
+  since timestamp without time zone NOT NULL,
+  CONSTRAINT host_states_pkey PRIMARY KEY (host),
+  CONSTRAINT host_states_host_fkey FOREIGN KEY (host)
+      REFERENCES public.hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+CREATE TABLE public.incidents
+(
+  id SERIAL NOT NULL,
+  host integer NOT NULL,
+  start_time timestamp without time zone NOT NULL,
+  end_time timestamp without time zone,
+  reason text NOT NULL,
+  checks bigint NOT NULL,
+  CONSTRAINT incidents_pkey PRIMARY KEY (id),
+  CONSTRAINT incidents_host_fkey FOREIGN KEY (host)
+      REFERENCES public.hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+CREATE INDEX incidents_host_idx
+  ON public.incidents
+  USING btree
+  (host, start_time);
+
+CREATE TABLE public.checks_rollups
+(
+  host integer NOT NULL,
+  resolution text NOT NULL,
+  rollup_time timestamp without time zone NOT NULL,
+  up_count bigint NOT NULL,
+  down_count bigint NOT NULL,
+  rtt_min bigint NOT NULL,
+  rtt_avg bigint NOT NULL,
+  rtt_max bigint NOT NULL,
+  CONSTRAINT checks_rollups_pkey PRIMARY KEY (host, resolution, rollup_time),
+  CONSTRAINT checks_rollups_host_fkey FOREIGN KEY (host)
+      REFERENCES public.hosts (id) MATCH SIMPLE
+      ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+CREATE TABLE public.maintenance_windows
+(
+  id SERIAL NOT NULL,
+  host text NOT NULL,
+  tag text NOT NULL,
+  start_time timestamp without time zone NOT NULL,
+  end_time timestamp without time zone NOT NULL,
+  schedule text NOT NULL,
+  duration_seconds bigint NOT NULL,
+  description text NOT NULL,
+  CONSTRAINT maintenance_windows_pkey PRIMARY KEY (id)
+);
+
+CREATE TABLE public.schema_version
+(
+  version bigint NOT NULL
+);
+
+INSERT INTO public.schema_version (version) VALUES (2);
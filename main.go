@@ -6,12 +6,9 @@ import (
 	"log"
 	"net"
 	"net/http"
-	"net/url"
 	"os"
 	"os/signal"
 	"regexp"
-	"strconv"
-	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -19,20 +16,16 @@ import (
 
 var MonData MonDB
 
-type CheckType int32
-
-const (
-	checkInvalid CheckType = -1
-	checkIcmp    CheckType = 0
-	checkHttp    CheckType = 1
-	checkTcp     CheckType = 2
-)
-
 var (
 	configPath = flag.String("config", "", "Config path")
 	configStr  = flag.String("confstr", "", "Pass config as a string")
 	checkHost  = flag.String("check", "", "Check single host")
 	initDB     = flag.Bool("init", false, "Init DB")
+	migrateDB  = flag.Bool("migrate", false, "Apply pending DB schema migrations and exit")
+	migrateDry = flag.Bool("migrate-dry-run", false, "List pending DB schema migrations and exit")
+	exportFile = flag.String("export", "", "Export all data to a file (\"-\" for stdout) and exit")
+	importFile = flag.String("import", "", "Import data from a file (\"-\" for stdin) and exit")
+	copyTo     = flag.String("copy-to", "", "Copy all data to another DB given as json DB config and exit")
 )
 
 var wg sync.WaitGroup
@@ -56,106 +49,44 @@ func isHostOrIP(host string) bool {
 	return true
 }
 
-func getCheckType(host string) CheckType {
-	if len(host) == 0 {
-		return checkInvalid
-	}
-	if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") {
-		_, err := url.ParseRequestURI(host)
-		if err != nil {
-			return checkInvalid
-		} else {
-			return checkHttp
-		}
-	}
-	var h []string
-	if strings.HasPrefix(host, "[") {
-		h = strings.Split(host[1:], "]:")
-	} else {
-		h = strings.Split(host, ":")
-	}
-	if len(h) == 2 {
-		if isHostOrIP(h[0]) {
-			if i, err := strconv.ParseInt(h[1], 10, 32); err == nil {
-				if i > 0 && i <= 65536 {
-					return checkTcp
-				}
-			}
-		}
-		return checkInvalid
-	}
-	if isHostOrIP(host) {
-		return checkIcmp
-	}
-	return checkInvalid
-}
-
-func doCheck(host string, checkTime time.Time) {
-	var rtt int64
-	var up bool
-	var err error
-
-	checkType := getCheckType(host)
-
-	switch checkType {
-	case checkIcmp:
-		rtt, up, err = PingCheck(host)
-	case checkHttp:
-		rtt, up, err = HttpCheck(host, Config.Checks.HTTPMethod)
-	case checkTcp:
-		rtt, up, err = TcpCheck(host)
-	default:
-		log.Println("[ERROR] Unknown checkType")
+func doCheck(host string, checkTime time.Time, s HostSettings) {
+	metricsObserveSchedulerDelay(time.Since(checkTime))
+	cData, err := runCheck(host, s)
+	if err == ErrNoCheckResult {
 		return
 	}
-
-	if err != nil {
-		up = false
-	}
-	go checkStateChange(host, rtt, checkTime, up)
-	err = MonData.SaveCheck(host, checkTime, rtt, up)
 	if err != nil {
-		log.Printf("[ERROR] %v", err)
-	}
-	wg.Done()
-}
-
-func checkTick(t time.Time) {
-	if !doProcess {
+		log.Printf("[ERROR] %v: %v", err, host)
 		return
 	}
-	hosts, err := MonData.GetHostsList()
-	if err != nil {
-		log.Printf("[ERROR] %v", err)
-		return
-	}
-	for _, host := range hosts {
-		wg.Add(1)
-		go doCheck(host, t)
-	}
-}
-
-func doSingleCheck(host string) (bool, time.Duration, error) {
-	var rtt int64
-	var up bool
-	var err error
+	cData.Timestamp = checkTime
+	cData.Maintenance = inMaintenance(host, checkTime)
+	metricsObserveCheck(host, cData)
+	publishCheckEvent(host, cData)
 
-	checkType := getCheckType(host)
+	go checkStateChange(host, cData)
+	queueCheckSave(host, cData)
+}
 
-	switch checkType {
-	case checkIcmp:
-		rtt, up, err = PingCheck(host)
-	case checkHttp:
-		rtt, up, err = HttpCheck(host, Config.Checks.HTTPMethod)
-	case checkTcp:
-		rtt, up, err = TcpCheck(host)
+func newMonDB(dbType string) MonDB {
+	switch dbType {
+	case "bolt":
+		return &MonDBBolt{}
+	case "pq":
+		return &MonDBPQ{}
+	case "ql":
+		return &MonDBQL{}
+	case "sqlite":
+		return &MonDBSQLite{}
 	default:
-		log.Println("[ERROR] Unknown checkType")
+		return &MonDBBolt{}
 	}
+}
 
-	rttDuration := time.Duration(rtt)
-
-	return up, rttDuration, err
+func doSingleCheck(host string) (ChecksData, error) {
+	cData, err := runCheck(host, getHostSettings(host))
+	cData.Timestamp = time.Now().UTC()
+	return cData, err
 }
 
 var ctx, ctxCancel = context.WithCancel(context.Background())
@@ -197,28 +128,21 @@ func main() {
 	}
 
 	if len(*checkHost) > 0 {
-		var up bool
-		var rtt time.Duration
-		up, rtt, err = doSingleCheck(*checkHost)
+		var cData ChecksData
+		cData, err = doSingleCheck(*checkHost)
 		if err == nil {
-			log.Printf("Up: %v, rtt: %v", up, rtt)
+			log.Printf("Up: %v, rtt: %v, reason: %v, status code: %v, ip: %v", cData.Up, time.Duration(cData.Rtt), cData.Reason, cData.StatusCode, cData.IP)
 		} else {
 			log.Printf("[ERROR] %v", err)
 		}
 		return
 	}
 
-	switch Config.DB.Type {
-	case "bolt":
-		MonData = &MonDBBolt{}
-	case "pq":
-		MonData = &MonDBPQ{}
-	case "ql":
-		MonData = &MonDBQL{}
-	default:
-		MonData = &MonDBBolt{}
-	}
+	MonData = &MonDBMetrics{newMonDB(Config.DB.Type)}
 
+	if *migrateDB || *migrateDry {
+		migrateOnOpen = false
+	}
 	err = MonData.Open(Config)
 	if err != nil {
 		log.Printf("[ERROR] %v", err)
@@ -235,6 +159,48 @@ func main() {
 		return
 	}
 
+	if *migrateDB || *migrateDry {
+		err = runMigrateMode(*migrateDry)
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+		}
+		return
+	}
+
+	if len(*exportFile) > 0 {
+		err = runExportMode(*exportFile)
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+		}
+		return
+	}
+
+	if len(*importFile) > 0 {
+		err = runImportMode(*importFile)
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+		}
+		return
+	}
+
+	if len(*copyTo) > 0 {
+		err = runCopyMode(*copyTo)
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+		}
+		return
+	}
+
+	err = loadCheckStates()
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+
+	err = loadMaintenanceWindows()
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+
 	http.HandleFunc(IndexTemplateHandlerRootEndpoint, IndexTemplateHandler)
 	http.HandleFunc(IndexTemplateHandlerHtmlEndpoint, IndexTemplateHandler)
 	http.HandleFunc(JsonHostsHandlerEndpoint, JsonHostsHandler)
@@ -248,6 +214,23 @@ func main() {
 	http.HandleFunc(JsonStateChangeParamsHandlerEndpoint, JsonStateChangeParamsHandler)
 	http.HandleFunc(JsonBackupHandlerEndpoint, JsonBackupHandler)
 	http.HandleFunc(JsonBackupFullHandlerEndpoint, JsonBackupFullHandler)
+	http.HandleFunc(EventsHandlerEndpoint, EventsHandler)
+	http.HandleFunc(JsonCertificatesHandlerEndpoint, JsonCertificatesHandler)
+	http.HandleFunc(JsonHTTPAssertionsHandlerEndpoint, JsonHTTPAssertionsHandler)
+	http.HandleFunc(MetricsHandlerEndpoint, MetricsHandler)
+	http.HandleFunc(JsonStatesHandlerEndpoint, JsonStatesHandler)
+	http.HandleFunc(JsonIncidentsHandlerEndpoint, JsonIncidentsHandler)
+	http.HandleFunc(JsonMaintenanceHandlerEndpoint, JsonMaintenanceHandler)
+	http.HandleFunc(JsonPushHandlerEndpoint, JsonPushHandler)
+	http.HandleFunc(JsonPushMonitorsHandlerEndpoint, JsonPushMonitorsHandler)
+	http.HandleFunc(IncidentsTemplateHandlerEndpoint, IncidentsTemplateHandler)
+	http.HandleFunc(JsonReportHandlerEndpoint, JsonReportHandler)
+	http.HandleFunc(JsonSLAHandlerEndpoint, JsonSLAHandler)
+	if Config.StatusPage.Enable {
+		http.HandleFunc(Config.StatusPage.Path, StatusPageHandler)
+	}
+	http.HandleFunc(JsonNotificationsLogHandlerEndpoint, JsonNotificationsLogHandler)
+	http.HandleFunc(NotificationsLogTemplateHandlerEndpoint, NotificationsLogTemplateHandler)
 	http.HandleFunc("/favicon.ico", func(res http.ResponseWriter, req *http.Request) {
 		res.Write([]byte{})
 	})
@@ -279,6 +262,11 @@ func main() {
 		}
 	}()
 
+	go checkWriter()
+	startCheckWorkers(Config.Checks.Workers)
+	go notificationsWorker()
+	go rollupsWorker()
+
 	signalChannel := make(chan os.Signal, 2)
 	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
 	go func() {
@@ -295,9 +283,14 @@ func main() {
 
 	dt := time.Duration(Config.Checks.Interval) * time.Second
 	retentiont := time.Duration(-Config.Checks.Retention) * time.Second
+	logRetentiont := time.Duration(-Config.Notifications.LogRetention) * time.Second
+	rollupsRetention := map[string]int64{
+		ResolutionHour: Config.Checks.HourlyRetention,
+		ResolutionDay:  Config.Checks.DailyRetention,
+	}
 	for doProcess {
 		t := time.Now()
-		if Config.Checks.Retention != 0 {
+		if Config.Checks.Retention != 0 && t.Truncate(dt).Equal(t.Truncate(time.Second)) {
 			go func() {
 				e := MonData.DeleteOldChecks(t.Truncate(dt).Add(retentiont))
 				if e != nil {
@@ -305,11 +298,33 @@ func main() {
 				}
 			}()
 		}
-		n := t.Truncate(dt).Add(dt)
+		for resolution, retention := range rollupsRetention {
+			if retention != 0 && t.Truncate(dt).Equal(t.Truncate(time.Second)) {
+				go func(resolution string, retention int64) {
+					e := MonData.DeleteOldRollups(resolution, t.Truncate(dt).Add(time.Duration(-retention)*time.Second))
+					if e != nil {
+						log.Printf("[ERROR] %v", e)
+					}
+				}(resolution, retention)
+			}
+		}
+		if Config.Notifications.LogRetention != 0 && t.Truncate(dt).Equal(t.Truncate(time.Second)) {
+			go func() {
+				e := MonData.DeleteOldNotificationLog(t.Truncate(dt).Add(logRetentiont))
+				if e != nil {
+					log.Printf("[ERROR] %v", e)
+				}
+			}()
+		}
+		//Step is a common divisor of all hosts intervals
+		step := getTickStep()
+		n := t.Truncate(step).Add(step)
 		d := n.Sub(t)
 		Wait(d)
 		if Config.Checks.PerformChecks {
 			go checkTick(n.UTC())
 		}
 	}
+	wg.Wait()
+	stopCheckWriter()
 }
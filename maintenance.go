@@ -0,0 +1,428 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaintenanceWindow is a period when a host (or every host with Tag) is
+// expected to be unavailable. Checks are still performed but marked as
+// maintenance, do not change the host state and are not counted in uptime.
+//
+// One-off windows last from Start to End. Recurring windows start at every
+// time that matches the cron Schedule (in ChecksTZ) and last Duration
+// seconds. Start and End optionally limit when a recurring window is used.
+type MaintenanceWindow struct {
+	ID          int64     `json:"id"`
+	Host        string    `json:"host,omitempty"`
+	Tag         string    `json:"tag,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Schedule    string    `json:"schedule,omitempty"`
+	Duration    int64     `json:"duration,omitempty"`
+	Description string    `json:"description,omitempty"`
+}
+
+// The start of a recurring window is searched back over its duration, so
+// it is limited.
+const maxMaintenanceDuration = 7 * 24 * time.Hour
+
+func (m MaintenanceWindow) Validate() error {
+	if (len(m.Host) == 0) == (len(m.Tag) == 0) {
+		return errors.New("Either host or tag must be set")
+	}
+	if len(m.Schedule) == 0 {
+		if m.Start.IsZero() || m.End.IsZero() || !m.End.After(m.Start) {
+			return errors.New("Bad start or end")
+		}
+		return nil
+	}
+	_, err := parseCronSchedule(m.Schedule)
+	if err != nil {
+		return err
+	}
+	if m.Duration <= 0 || time.Duration(m.Duration)*time.Second > maxMaintenanceDuration {
+		return errors.New("Bad duration")
+	}
+	if !m.Start.IsZero() && !m.End.IsZero() && !m.End.After(m.Start) {
+		return errors.New("Bad start or end")
+	}
+	return nil
+}
+
+// cronSchedule is a parsed cron expression with minute, hour, day of month,
+// month and day of week fields. Each field is a bit set of allowed values.
+type cronSchedule struct {
+	minute  uint64
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	domStar bool
+	dowStar bool
+}
+
+// parseCronField parses a comma separated list of values, ranges (1-5),
+// steps (*/15, 1-30/5) and * for every value.
+func parseCronField(field string, min int, max int) (bits uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		var step int = 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("Bad cron step: %v", part)
+			}
+			part = part[:i]
+		}
+		var from, to int
+		if part == "*" {
+			from, to = min, max
+		} else if i := strings.Index(part, "-"); i >= 0 {
+			from, err = strconv.Atoi(part[:i])
+			if err != nil {
+				return 0, fmt.Errorf("Bad cron range: %v", part)
+			}
+			to, err = strconv.Atoi(part[i+1:])
+			if err != nil {
+				return 0, fmt.Errorf("Bad cron range: %v", part)
+			}
+		} else {
+			from, err = strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("Bad cron value: %v", part)
+			}
+			to = from
+		}
+		if from < min || to > max || from > to {
+			return 0, fmt.Errorf("Cron value out of range: %v", part)
+		}
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseCronSchedule(s string) (c cronSchedule, err error) {
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return c, errors.New("Cron schedule must have 5 fields")
+	}
+	c.minute, err = parseCronField(fields[0], 0, 59)
+	if err != nil {
+		return c, err
+	}
+	c.hour, err = parseCronField(fields[1], 0, 23)
+	if err != nil {
+		return c, err
+	}
+	c.dom, err = parseCronField(fields[2], 1, 31)
+	if err != nil {
+		return c, err
+	}
+	c.month, err = parseCronField(fields[3], 1, 12)
+	if err != nil {
+		return c, err
+	}
+	//Both 0 and 7 are Sunday
+	c.dow, err = parseCronField(fields[4], 0, 7)
+	if err != nil {
+		return c, err
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar = strings.HasPrefix(fields[2], "*")
+	c.dowStar = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// Match reports whether the minute of t matches the schedule. Like in cron
+// if both day of month and day of week are restricted either of them can
+// match.
+func (c cronSchedule) Match(t time.Time) bool {
+	if c.minute&(1<<uint(t.Minute())) == 0 || c.hour&(1<<uint(t.Hour())) == 0 {
+		return false
+	}
+	return c.matchDay(t)
+}
+
+func (c cronSchedule) matchDay(t time.Time) bool {
+	if c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// prev returns the last minute after from and at or before t that matches
+// the schedule. Minutes are walked back like in cron, but days, hours and
+// minutes that can not match are skipped at once. A skip is not done over
+// a time zone offset change, then a single minute is stepped back.
+func (c cronSchedule) prev(t time.Time, from time.Time) (time.Time, bool) {
+	for s := t.Truncate(time.Minute); s.After(from); {
+		var next time.Time
+		if !c.matchDay(s) {
+			next = s.Add(-time.Duration(s.Hour()*60+s.Minute()+1) * time.Minute)
+		} else if c.hour&(1<<uint(s.Hour())) == 0 {
+			next = s.Add(-time.Duration(s.Minute()+1) * time.Minute)
+		} else if c.minute&(1<<uint(s.Minute())) != 0 {
+			return s, true
+		} else if m := c.minute & (1<<uint(s.Minute()) - 1); m != 0 {
+			next = s.Add(-time.Duration(s.Minute()-(bits.Len64(m)-1)) * time.Minute)
+		} else {
+			next = s.Add(-time.Duration(s.Minute()+1) * time.Minute)
+		}
+		_, offset := s.Zone()
+		_, nextOffset := next.Add(time.Minute).Zone()
+		if offset != nextOffset {
+			next = s.Add(-time.Minute)
+		}
+		s = next
+	}
+	return time.Time{}, false
+}
+
+type maintenanceEntry struct {
+	MaintenanceWindow
+	schedule cronSchedule
+}
+
+// Active reports whether t is inside the window.
+func (m maintenanceEntry) Active(t time.Time) bool {
+	if len(m.Schedule) == 0 {
+		return !t.Before(m.Start) && t.Before(m.End)
+	}
+	if !m.Start.IsZero() && t.Before(m.Start) {
+		return false
+	}
+	if !m.End.IsZero() && !t.Before(m.End) {
+		return false
+	}
+	t = t.In(ChecksTZ)
+	from := t.Add(-time.Duration(m.Duration) * time.Second)
+	_, ok := m.schedule.prev(t, from)
+	return ok
+}
+
+var maintenanceWindows []maintenanceEntry
+var maintenanceWindowsMux sync.RWMutex
+
+// loadMaintenanceWindows refreshes the cached windows from the DB.
+func loadMaintenanceWindows() error {
+	list, err := MonData.GetMaintenanceWindows()
+	if err != nil {
+		return err
+	}
+	entries := make([]maintenanceEntry, 0, len(list))
+	for _, m := range list {
+		e := maintenanceEntry{MaintenanceWindow: m}
+		if len(m.Schedule) > 0 {
+			e.schedule, err = parseCronSchedule(m.Schedule)
+			if err != nil {
+				log.Printf("[ERROR] maintenance window %v: %v", m.ID, err)
+				continue
+			}
+		}
+		entries = append(entries, e)
+	}
+	maintenanceWindowsMux.Lock()
+	maintenanceWindows = entries
+	maintenanceWindowsMux.Unlock()
+	return nil
+}
+
+// inMaintenance reports whether host has an active maintenance window at t.
+// The cached list is replaced on reload and never changed, so it is used
+// without holding the lock. Host meta is read only if a tag window exists.
+func inMaintenance(host string, t time.Time) bool {
+	maintenanceWindowsMux.RLock()
+	windows := maintenanceWindows
+	maintenanceWindowsMux.RUnlock()
+	var meta *HostMeta
+	for _, m := range windows {
+		if len(m.Host) > 0 && m.Host != host {
+			continue
+		}
+		if len(m.Tag) > 0 {
+			if meta == nil {
+				hm := getHostMeta(host)
+				meta = &hm
+			}
+			if !meta.HasTag(m.Tag) {
+				continue
+			}
+		}
+		if m.Active(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func AddMaintenanceWindow(m MaintenanceWindow) error {
+	m.Start = m.Start.UTC()
+	m.End = m.End.UTC()
+	err := m.Validate()
+	if err != nil {
+		return err
+	}
+	if len(m.Host) > 0 {
+		err = MonData.CheckHostExists(m.Host)
+		if err != nil {
+			return err
+		}
+	}
+	err = MonData.AddMaintenanceWindow(m)
+	if err != nil {
+		return err
+	}
+	return loadMaintenanceWindows()
+}
+
+func DeleteMaintenanceWindow(id int64) error {
+	err := MonData.DeleteMaintenanceWindow(id)
+	if err != nil {
+		return err
+	}
+	return loadMaintenanceWindows()
+}
+
+// getMaintenanceWindows returns windows of host (including windows of its
+// tags) or all windows if host is empty.
+func getMaintenanceWindows(host string) (list []MaintenanceWindow, err error) {
+	list = make([]MaintenanceWindow, 0)
+	all, err := MonData.GetMaintenanceWindows()
+	if err != nil {
+		return list, err
+	}
+	if len(host) == 0 {
+		return all, nil
+	}
+	meta := getHostMeta(host)
+	for _, m := range all {
+		if m.Host == host || (len(m.Tag) > 0 && meta.HasTag(m.Tag)) {
+			list = append(list, m)
+		}
+	}
+	return list, nil
+}
+
+const JsonMaintenanceHandlerEndpoint string = "/api/maintenance"
+
+func JsonMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := getMaintenanceWindows(r.URL.Query().Get("host"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		jsonData, err := json.Marshal(list)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, err = w.Write(jsonData)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		return
+
+	case http.MethodPost:
+		if Config.Listen.WebAuth.Enable {
+			username, password, authOK := r.BasicAuth()
+			if authOK == false {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("401 - Not authorized"))
+				return
+			}
+
+			if username != Config.Listen.WebAuth.User || password != Config.Listen.WebAuth.Password {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("401 - Not authorized"))
+				return
+			}
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		var m MaintenanceWindow
+		err = json.Unmarshal(body, &m)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		err = AddMaintenanceWindow(m)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		return
+
+	case http.MethodDelete:
+		if Config.Listen.WebAuth.Enable {
+			username, password, authOK := r.BasicAuth()
+			if authOK == false {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("401 - Not authorized"))
+				return
+			}
+
+			if username != Config.Listen.WebAuth.User || password != Config.Listen.WebAuth.Password {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("401 - Not authorized"))
+				return
+			}
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		id, err := strconv.ParseInt(strings.TrimSpace(string(body)), 10, 64)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		err = DeleteMaintenanceWindow(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		return
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
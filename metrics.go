@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics are kept in memory and exported in Prometheus text format.
+// Counters start from zero on every restart.
+
+var metricsLatencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type metricsHistogram struct {
+	Buckets []float64
+	Counts  []uint64
+	Sum     float64
+	Count   uint64
+}
+
+func newMetricsHistogram(buckets []float64) *metricsHistogram {
+	return &metricsHistogram{Buckets: buckets, Counts: make([]uint64, len(buckets))}
+}
+
+func (h *metricsHistogram) Observe(v float64) {
+	for i, b := range h.Buckets {
+		if v <= b {
+			h.Counts[i]++
+		}
+	}
+	h.Sum += v
+	h.Count++
+}
+
+type hostMetrics struct {
+	Up           bool
+	Rtt          time.Duration
+	LastCheck    time.Time
+	ChecksTotal  uint64
+	ChecksFailed uint64
+}
+
+var metricsHosts = make(map[string]*hostMetrics)
+var metricsNotifications = map[string]uint64{"success": 0, "failure": 0}
+var metricsSchedulerDelay = newMetricsHistogram(metricsLatencyBuckets)
+var metricsSchedulerLag time.Duration
+var metricsSchedulerOverruns uint64
+var metricsDBLatency = map[string]*metricsHistogram{
+	"SaveCheck":     newMetricsHistogram(metricsLatencyBuckets),
+	"SaveChecks":    newMetricsHistogram(metricsLatencyBuckets),
+	"GetChecksData": newMetricsHistogram(metricsLatencyBuckets),
+}
+var metricsMux sync.Mutex
+
+func metricsObserveCheck(host string, cData ChecksData) {
+	metricsMux.Lock()
+	defer metricsMux.Unlock()
+	m, ok := metricsHosts[host]
+	if !ok {
+		m = &hostMetrics{}
+		metricsHosts[host] = m
+	}
+	m.Up = cData.Up
+	m.Rtt = time.Duration(cData.Rtt)
+	m.LastCheck = cData.Timestamp
+	m.ChecksTotal++
+	if !cData.Up {
+		m.ChecksFailed++
+	}
+}
+
+func metricsObserveNotification(err error) {
+	metricsMux.Lock()
+	if err == nil {
+		metricsNotifications["success"]++
+	} else {
+		metricsNotifications["failure"]++
+	}
+	metricsMux.Unlock()
+}
+
+// metricsObserveSchedulerDelay records how late a check was started
+// relative to its scheduled time.
+func metricsObserveSchedulerDelay(d time.Duration) {
+	metricsMux.Lock()
+	metricsSchedulerDelay.Observe(d.Seconds())
+	metricsSchedulerLag = d
+	metricsMux.Unlock()
+}
+
+// metricsObserveSchedulerOverrun counts checks skipped because the previous
+// check of the host was still running.
+func metricsObserveSchedulerOverrun() {
+	metricsMux.Lock()
+	metricsSchedulerOverruns++
+	metricsMux.Unlock()
+}
+
+func metricsObserveDB(operation string, start time.Time) {
+	d := time.Since(start)
+	metricsMux.Lock()
+	if h, ok := metricsDBLatency[operation]; ok {
+		h.Observe(d.Seconds())
+	}
+	metricsMux.Unlock()
+}
+
+// MonDBMetrics wraps a MonDB and records latencies of the hot paths.
+type MonDBMetrics struct {
+	MonDB
+}
+
+func (d *MonDBMetrics) SaveCheck(host string, cData ChecksData) error {
+	defer metricsObserveDB("SaveCheck", time.Now())
+	return d.MonDB.SaveCheck(host, cData)
+}
+
+func (d *MonDBMetrics) SaveChecks(checks []HostCheck) error {
+	defer metricsObserveDB("SaveChecks", time.Now())
+	return d.MonDB.SaveChecks(checks)
+}
+
+func (d *MonDBMetrics) GetChecksData(chkReq ChecksRequest) ([]ChecksData, error) {
+	defer metricsObserveDB("GetChecksData", time.Now())
+	return d.MonDB.GetChecksData(chkReq)
+}
+
+var metricsLabelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func metricsFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func writeMetricsHeader(sb *strings.Builder, name string, metricType string, help string) {
+	fmt.Fprintf(sb, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(sb, "# TYPE %s %s\n", name, metricType)
+}
+
+func writeMetricsHistogram(sb *strings.Builder, name string, labels string, h *metricsHistogram) {
+	sep := ""
+	if len(labels) > 0 {
+		sep = ","
+	}
+	for i, b := range h.Buckets {
+		fmt.Fprintf(sb, "%s_bucket{%s%sle=\"%s\"} %d\n", name, labels, sep, metricsFloat(b), h.Counts[i])
+	}
+	fmt.Fprintf(sb, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, h.Count)
+	if len(labels) > 0 {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(sb, "%s_sum%s %s\n", name, labels, metricsFloat(h.Sum))
+	fmt.Fprintf(sb, "%s_count%s %d\n", name, labels, h.Count)
+}
+
+func getMetrics(hosts []string) string {
+	var sb strings.Builder
+	sort.Strings(hosts)
+
+	metricsMux.Lock()
+	defer metricsMux.Unlock()
+
+	type hostMetricsLine struct {
+		host string
+		m    *hostMetrics
+	}
+	var lines []hostMetricsLine
+	for _, h := range hosts {
+		if m, ok := metricsHosts[h]; ok {
+			lines = append(lines, hostMetricsLine{metricsLabelEscaper.Replace(h), m})
+		}
+	}
+
+	writeMetricsHeader(&sb, "gosrvmon_host_up", "gauge", "Whether the last check of the host succeeded.")
+	for _, l := range lines {
+		var up int = 0
+		if l.m.Up {
+			up = 1
+		}
+		fmt.Fprintf(&sb, "gosrvmon_host_up{host=\"%s\"} %d\n", l.host, up)
+	}
+	writeMetricsHeader(&sb, "gosrvmon_host_rtt_seconds", "gauge", "Round trip time of the last check of the host.")
+	for _, l := range lines {
+		fmt.Fprintf(&sb, "gosrvmon_host_rtt_seconds{host=\"%s\"} %s\n", l.host, metricsFloat(l.m.Rtt.Seconds()))
+	}
+	writeMetricsHeader(&sb, "gosrvmon_host_last_check_timestamp_seconds", "gauge", "Unix time of the last check of the host.")
+	for _, l := range lines {
+		fmt.Fprintf(&sb, "gosrvmon_host_last_check_timestamp_seconds{host=\"%s\"} %d\n", l.host, l.m.LastCheck.Unix())
+	}
+	writeMetricsHeader(&sb, "gosrvmon_checks_total", "counter", "Number of checks performed.")
+	for _, l := range lines {
+		fmt.Fprintf(&sb, "gosrvmon_checks_total{host=\"%s\"} %d\n", l.host, l.m.ChecksTotal)
+	}
+	writeMetricsHeader(&sb, "gosrvmon_checks_failed_total", "counter", "Number of checks that found the host offline.")
+	for _, l := range lines {
+		fmt.Fprintf(&sb, "gosrvmon_checks_failed_total{host=\"%s\"} %d\n", l.host, l.m.ChecksFailed)
+	}
+
+	writeMetricsHeader(&sb, "gosrvmon_notifications_total", "counter", "Number of state change notifications sent.")
+	fmt.Fprintf(&sb, "gosrvmon_notifications_total{result=\"success\"} %d\n", metricsNotifications["success"])
+	fmt.Fprintf(&sb, "gosrvmon_notifications_total{result=\"failure\"} %d\n", metricsNotifications["failure"])
+
+	writeMetricsHeader(&sb, "gosrvmon_scheduler_delay_seconds", "histogram", "Delay between scheduled and actual start of checks.")
+	writeMetricsHistogram(&sb, "gosrvmon_scheduler_delay_seconds", "", metricsSchedulerDelay)
+	writeMetricsHeader(&sb, "gosrvmon_scheduler_lag_seconds", "gauge", "Delay of the most recently started check.")
+	fmt.Fprintf(&sb, "gosrvmon_scheduler_lag_seconds %s\n", metricsFloat(metricsSchedulerLag.Seconds()))
+	writeMetricsHeader(&sb, "gosrvmon_scheduler_overruns_total", "counter", "Number of checks skipped because the previous check of the host was still running.")
+	fmt.Fprintf(&sb, "gosrvmon_scheduler_overruns_total %d\n", metricsSchedulerOverruns)
+	writeMetricsHeader(&sb, "gosrvmon_scheduler_queue_length", "gauge", "Number of checks waiting for a free worker.")
+	fmt.Fprintf(&sb, "gosrvmon_scheduler_queue_length %d\n", len(checkJobs))
+
+	writeMetricsHeader(&sb, "gosrvmon_db_operation_duration_seconds", "histogram", "Duration of database operations.")
+	var operations []string
+	for op := range metricsDBLatency {
+		operations = append(operations, op)
+	}
+	sort.Strings(operations)
+	for _, op := range operations {
+		writeMetricsHistogram(&sb, "gosrvmon_db_operation_duration_seconds", "operation=\""+op+"\"", metricsDBLatency[op])
+	}
+
+	return sb.String()
+}
+
+const MetricsHandlerEndpoint string = "/metrics"
+
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	//Only hosts that are still monitored are exported
+	hosts, err := MonData.GetHostsList()
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, err = w.Write([]byte(getMetrics(hosts)))
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		return
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Migration changes the DB schema from the previous version to Version.
+// Version 1 is the schema created by versions without migrations. Each
+// backend keeps an ordered list of migrations and applies the ones newer
+// than the stored version when the DB is opened. Every migration is applied
+// in its own transaction together with the new version record.
+type Migration struct {
+	Version     int64  `json:"version"`
+	Description string `json:"description"`
+}
+
+type sqlMigration struct {
+	Migration
+	Apply func(tx *sql.Tx) error
+}
+
+// migrateOnOpen is disabled for -migrate and -migrate-dry-run modes, so
+// pending migrations can be listed before they are applied.
+var migrateOnOpen bool = true
+
+// getSchemaVersionCommon returns the stored schema version, 1 for DBs
+// created before migrations were added or 0 if the DB is not initialised.
+// tableExistsQuery must return the number of tables with name $1.
+func getSchemaVersionCommon(db *sql.DB, tableExistsQuery string) (version int64, err error) {
+	var n int64
+	err = db.QueryRow(tableExistsQuery, "schema_version").Scan(&n)
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		var v sql.NullInt64
+		err = db.QueryRow("SELECT max(version) FROM schema_version;").Scan(&v)
+		if err != nil {
+			return 0, err
+		}
+		if v.Valid {
+			return v.Int64, nil
+		}
+	}
+	err = db.QueryRow(tableExistsQuery, "hosts").Scan(&n)
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// migrateSQLCommon applies migrations newer than current. It returns the
+// migrations that were applied or would be applied if dryRun is set.
+// versionTable must create the schema_version table if it does not exist.
+func migrateSQLCommon(db *sql.DB, current int64, migrations []sqlMigration, versionTable string, dryRun bool) (applied []Migration, err error) {
+	applied = make([]Migration, 0)
+	if current == 0 {
+		return applied, ErrDBNotInitialised
+	}
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if dryRun {
+			applied = append(applied, m.Migration)
+			continue
+		}
+		err = execTxCommon(db, func(tx *sql.Tx) error {
+			e := m.Apply(tx)
+			if e != nil {
+				return e
+			}
+			e = execStmtCommon(tx, versionTable)
+			if e != nil {
+				return e
+			}
+			e = execStmtCommon(tx, "DELETE FROM schema_version;")
+			if e != nil {
+				return e
+			}
+			return execStmtCommon(tx, "INSERT INTO schema_version (version) VALUES ($1);", m.Version)
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %v", m.Version, m.Description, err)
+		}
+		log.Printf("Applied migration %d: %s", m.Version, m.Description)
+		applied = append(applied, m.Migration)
+	}
+	return applied, nil
+}
+
+// runMigrateMode applies (or lists if dryRun is set) pending migrations for
+// -migrate and -migrate-dry-run options.
+func runMigrateMode(dryRun bool) error {
+	version, err := MonData.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	log.Printf("Current schema version: %d", version)
+	list, err := MonData.Migrate(dryRun)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		log.Println("Schema is up to date")
+		return nil
+	}
+	if dryRun {
+		for _, m := range list {
+			log.Printf("Pending migration %d: %s", m.Version, m.Description)
+		}
+	}
+	return nil
+}
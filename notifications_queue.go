@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// QueuedNotification is a rendered notification request waiting for
+// delivery. It is removed from the queue after it was delivered or after
+// Config.Notifications.MaxAttempts failed attempts.
+type QueuedNotification struct {
+	ID          int64             `json:"id"`
+	Host        string            `json:"host"`
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        string            `json:"body,omitempty"`
+	Attempts    int64             `json:"attempts"`
+	NextAttempt time.Time         `json:"next_attempt"`
+	Created     time.Time         `json:"created"`
+}
+
+// NotificationLogEntry records a single delivery attempt.
+type NotificationLogEntry struct {
+	Host       string    `json:"host"`
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	URL        string    `json:"url"`
+	Attempt    int64     `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Delivered  bool      `json:"delivered"`
+}
+
+const notificationsPollInterval = 5 * time.Second
+
+var notificationsWake = make(chan struct{}, 1)
+
+// QueueEventNotification renders a state change notification and queues it
+// for delivery.
+func QueueEventNotification(host string, cData ChecksData, p StateChangeParams, prevDuration time.Duration) error {
+	n, err := PrepareEventNotification(host, cData, p, prevDuration)
+	if err != nil {
+		return err
+	}
+
+	n.Created = time.Now().UTC()
+	n.NextAttempt = n.Created
+	err = MonData.AddQueuedNotification(n)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case notificationsWake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// notificationRetryDelay doubles the retry interval after every failed
+// attempt up to MaxRetryInterval.
+func notificationRetryDelay(attempts int64) time.Duration {
+	delay := Config.Notifications.RetryInterval
+	for i := int64(1); i < attempts && delay < Config.Notifications.MaxRetryInterval; i++ {
+		delay *= 2
+	}
+	if delay > Config.Notifications.MaxRetryInterval {
+		delay = Config.Notifications.MaxRetryInterval
+	}
+	return time.Duration(delay) * time.Second
+}
+
+func deliverNotification(n QueuedNotification) {
+	n.Attempts++
+	statusCode, err := SendNotification(n)
+	metricsObserveNotification(err)
+
+	l := NotificationLogEntry{
+		Host:       n.Host,
+		Time:       time.Now().UTC(),
+		Method:     n.Method,
+		URL:        n.URL,
+		Attempt:    n.Attempts,
+		StatusCode: statusCode,
+		Delivered:  err == nil,
+	}
+	if err != nil {
+		l.Error = err.Error()
+		log.Printf("[ERROR] notification for %v attempt %v: %v", n.Host, n.Attempts, err)
+	}
+	e := MonData.AddNotificationLog(l)
+	if e != nil {
+		log.Printf("[ERROR] %v", e)
+	}
+
+	if err == nil || n.Attempts >= Config.Notifications.MaxAttempts {
+		if err != nil {
+			log.Printf("[ERROR] notification for %v dropped after %v attempts", n.Host, n.Attempts)
+		}
+		e = MonData.DeleteQueuedNotification(n.ID)
+		if e != nil {
+			log.Printf("[ERROR] %v", e)
+		}
+		return
+	}
+
+	n.NextAttempt = time.Now().UTC().Add(notificationRetryDelay(n.Attempts))
+	e = MonData.UpdateQueuedNotification(n)
+	if e != nil {
+		log.Printf("[ERROR] %v", e)
+	}
+}
+
+func processNotificationsQueue() {
+	queue, err := MonData.GetQueuedNotifications()
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		return
+	}
+	now := time.Now().UTC()
+	for _, n := range queue {
+		if !doProcess {
+			return
+		}
+		if n.NextAttempt.After(now) {
+			continue
+		}
+		deliverNotification(n)
+	}
+}
+
+// notificationsWorker delivers queued notifications until shutdown.
+func notificationsWorker() {
+	for doProcess {
+		processNotificationsQueue()
+		select {
+		case <-ctx.Done():
+			return
+		case <-shutdownChan:
+			return
+		case <-notificationsWake:
+		case <-time.After(notificationsPollInterval):
+		}
+	}
+}
+
+func getNotificationLogRequest(r *http.Request) (host string, limit int, err error) {
+	host = r.URL.Query().Get("host")
+	limit = 100
+	if l := r.URL.Query().Get("limit"); len(l) > 0 {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			return host, limit, fmt.Errorf("Bad limit")
+		}
+	}
+	return host, limit, nil
+}
+
+const JsonNotificationsLogHandlerEndpoint string = "/api/notifications/log"
+
+func JsonNotificationsLogHandler(w http.ResponseWriter, r *http.Request) {
+	if Config.Listen.WebAuth.Enable {
+		username, password, authOK := r.BasicAuth()
+		if authOK == false {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("401 - Not authorized"))
+			return
+		}
+
+		if username != Config.Listen.WebAuth.User || password != Config.Listen.WebAuth.Password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("401 - Not authorized"))
+			return
+		}
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	host, limit, err := getNotificationLogRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	l, err := MonData.GetNotificationLog(host, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonData, err := json.Marshal(l)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(jsonData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+const notificationsLogTemplateDoc string = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="UTF-8">
+  <title>Notifications</title>
+  <style>
+    td {padding-right: 1em;}
+  </style>
+</head>
+
+<body>
+
+<h2>Queued</h2>
+<table id="queue">
+  <tr>
+	<th>Host</th>
+	<th>Created</th>
+	<th>Method</th>
+	<th>URL</th>
+	<th>Attempts</th>
+	<th>Next attempt</th>
+  </tr>
+{{range .Queue}}
+  <tr>
+	<td>{{.Host}}</td>
+	<td>{{.Created}}</td>
+	<td>{{.Method}}</td>
+	<td>{{.URL}}</td>
+	<td>{{.Attempts}}</td>
+	<td>{{.NextAttempt}}</td>
+  </tr>
+{{end}}
+</table>
+
+<h2>Delivery log</h2>
+<table id="log">
+  <tr>
+	<th>Time</th>
+	<th>Host</th>
+	<th>Method</th>
+	<th>URL</th>
+	<th>Attempt</th>
+	<th>Delivered</th>
+	<th>Status code</th>
+	<th>Error</th>
+  </tr>
+{{range .Log}}
+  <tr>
+	<td>{{.Time}}</td>
+	<td><a href="` + HostsViewTemplateHandlerEndpoint + `?host={{.Host}}">{{.Host}}</a></td>
+	<td>{{.Method}}</td>
+	<td>{{.URL}}</td>
+	<td>{{.Attempt}}</td>
+	<td>{{.Delivered}}</td>
+	<td>{{if .StatusCode}}{{.StatusCode}}{{end}}</td>
+	<td>{{.Error}}</td>
+  </tr>
+{{end}}
+</table>
+
+</body>
+</html>
+`
+
+type NotificationsLogPageData struct {
+	Queue []QueuedNotification
+	Log   []NotificationLogEntry
+}
+
+var notificationsLogTemplate = template.Must(template.New("Notifications Log Template").Parse(notificationsLogTemplateDoc))
+
+const NotificationsLogTemplateHandlerEndpoint string = "/web/notifications/log"
+
+func NotificationsLogTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	if Config.Listen.WebAuth.Enable {
+		username, password, authOK := r.BasicAuth()
+		if authOK == false {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("401 - Not authorized"))
+			return
+		}
+
+		if username != Config.Listen.WebAuth.User || password != Config.Listen.WebAuth.Password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("401 - Not authorized"))
+			return
+		}
+	}
+
+	host, limit, err := getNotificationLogRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var data NotificationsLogPageData
+	data.Queue, err = MonData.GetQueuedNotifications()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data.Log, err = MonData.GetNotificationLog(host, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = notificationsLogTemplate.Execute(w, data)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+}
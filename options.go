@@ -36,18 +36,39 @@ type Configuration struct {
 		RemoteChecksURLs  []string
 		AllowSingleChecks bool
 		Retention         int64
+		HourlyRetention   int64
+		DailyRetention    int64
+		CertExpiryDays    int64
+		CheckHTTPSCerts   bool
+		PushGrace         int64
+		Workers           int64
+		SpreadChecks      bool
+	}
+	Notifications struct {
+		MaxAttempts      int64
+		RetryInterval    int64
+		MaxRetryInterval int64
+		LogRetention     int64
 	}
 	Chart struct {
 		MaxRttScale     int64
 		DynamicRttScale bool
 		TimeZone        string
 	}
+	StatusPage struct {
+		Enable     bool
+		Path       string
+		Title      string
+		Days       int64
+		Components []StatusPageComponent
+	}
 }
 
 var Config = Configuration{}
 
 func loadConfiguration() error {
 	Config.Checks.PerformChecks = true
+	Config.Checks.SpreadChecks = true
 
 	if *configPath != "" {
 		file, err := os.Open(*configPath)
@@ -93,6 +114,27 @@ func loadConfiguration() error {
 	if Config.Checks.HTTPMethod == "" {
 		Config.Checks.HTTPMethod = "GET"
 	}
+	if Config.Checks.CertExpiryDays == 0 {
+		Config.Checks.CertExpiryDays = 14
+	}
+	if Config.Checks.Workers < 1 {
+		Config.Checks.Workers = 32
+	}
+	if Config.Checks.PushGrace <= 0 {
+		Config.Checks.PushGrace = 60
+	}
+	if Config.Notifications.MaxAttempts < 1 {
+		Config.Notifications.MaxAttempts = 5
+	}
+	if Config.Notifications.RetryInterval <= 0 {
+		Config.Notifications.RetryInterval = 30
+	}
+	if Config.Notifications.MaxRetryInterval <= 0 {
+		Config.Notifications.MaxRetryInterval = 3600
+	}
+	if Config.Notifications.MaxRetryInterval < Config.Notifications.RetryInterval {
+		Config.Notifications.MaxRetryInterval = Config.Notifications.RetryInterval
+	}
 	if Config.Chart.MaxRttScale <= 0 {
 		Config.Chart.MaxRttScale = 200
 	}
@@ -108,5 +150,14 @@ func loadConfiguration() error {
 	if Config.Chart.TimeZone == "" {
 		Config.Chart.TimeZone = "UTC"
 	}
+	if Config.StatusPage.Path == "" {
+		Config.StatusPage.Path = "/status"
+	}
+	if Config.StatusPage.Title == "" {
+		Config.StatusPage.Title = "Status"
+	}
+	if Config.StatusPage.Days <= 0 {
+		Config.StatusPage.Days = 90
+	}
 	return nil
 }
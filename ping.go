@@ -6,9 +6,25 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"time"
 )
 
+type PingChecker struct{}
+
+func init() {
+	RegisterChecker("icmp", PingChecker{})
+}
+
+func (PingChecker) Validate(host string) bool {
+	return isHostOrIP(stripCheckScheme(host))
+}
+
+func (PingChecker) Check(host string, s HostSettings) (res ChecksData, err error) {
+	return PingCheck(stripCheckScheme(host), s.Retries, s.TimeoutDuration())
+}
+
 type PingCheckResult struct {
+	ip  string
 	rtt int64
 	up  bool
 	err error
@@ -18,19 +34,20 @@ func IsIPv4(ad string) bool {
 	return len(ad) > 0 && !strings.Contains(ad, ":")
 }
 
-func PingCheck(host string) (rtt int64, up bool, err error) {
+func PingCheck(host string, retries uint32, timeout time.Duration) (res ChecksData, err error) {
+	res.Rtt = -1
 	var addr []net.IP
 	addr, err = net.LookupIP(host)
 	if err != nil {
-		return -1, false, nil
+		res.Reason = err.Error()
+		return res, nil
 	}
 	if len(addr) == 0 {
-		return -1, false, errors.New("host has no A/AAAA records")
+		return res, errors.New("host has no A/AAAA records")
 	}
-	rtt = -1
-	up = false
+	res.Reason = "no echo reply"
 	err = nil
-	for i := uint32(0); i < Config.Checks.PingRetryCount; i++ {
+	for i := uint32(0); i < retries; i++ {
 		c := make(chan PingCheckResult)
 		go func() {
 			var wg sync.WaitGroup
@@ -41,7 +58,8 @@ func PingCheck(host string) (rtt int64, up bool, err error) {
 						wg.Add(1)
 						go func() {
 							var pingRes PingCheckResult
-							pingRes.rtt, pingRes.up, pingRes.err = Ping(adstr, true)
+							pingRes.ip = adstr
+							pingRes.rtt, pingRes.up, pingRes.err = Ping(adstr, true, timeout)
 							c <- pingRes
 							wg.Done()
 						}()
@@ -49,7 +67,8 @@ func PingCheck(host string) (rtt int64, up bool, err error) {
 						wg.Add(1)
 						go func() {
 							var pingRes PingCheckResult
-							pingRes.rtt, pingRes.up, pingRes.err = Ping(adstr, false)
+							pingRes.ip = adstr
+							pingRes.rtt, pingRes.up, pingRes.err = Ping(adstr, false, timeout)
 							c <- pingRes
 							wg.Done()
 						}()
@@ -62,17 +81,20 @@ func PingCheck(host string) (rtt int64, up bool, err error) {
 		for pingRes := range c {
 			if pingRes.err != nil {
 				log.Printf("[ERROR] %v", pingRes.err)
+				if !res.Up {
+					res.Reason = pingRes.err.Error()
+				}
 			} else {
 				if pingRes.up {
-					if !up {
-						up = pingRes.up
-						rtt = pingRes.rtt
-					} else if pingRes.rtt < rtt {
-						rtt = pingRes.rtt
+					if !res.Up || pingRes.rtt < res.Rtt {
+						res.Up = true
+						res.Rtt = pingRes.rtt
+						res.IP = pingRes.ip
+						res.Reason = ""
 					}
 				}
 			}
 		}
 	}
-	return
+	return res, nil
 }
@@ -6,9 +6,7 @@ import (
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/ipv6"
 	"net"
-	"net/url"
 	"os"
-	"syscall"
 	"time"
 )
 
@@ -25,7 +23,7 @@ func ipv4Payload(b []byte) []byte {
 
 //rtt is in nanoseconds
 //TODO: check checksumm
-func Ping(host string, isV4 bool) (rtt int64, up bool, err error) {
+func Ping(host string, isV4 bool, timeout time.Duration) (rtt int64, up bool, err error) {
 	//Prevent panic
 	defer func() {
 		if r := recover(); r != nil {
@@ -46,7 +44,7 @@ func Ping(host string, isV4 bool) (rtt int64, up bool, err error) {
 		return -1, false, nil
 	}
 
-	err = c.SetDeadline(time.Now().Add(time.Duration(Config.Checks.Timeout) * time.Second))
+	err = c.SetDeadline(time.Now().Add(timeout))
 	if err != nil {
 		return -1, false, err
 	}
@@ -96,37 +94,8 @@ func Ping(host string, isV4 bool) (rtt int64, up bool, err error) {
 	n, err = c.Read(rsp)
 	rtt = int64(time.Since(start))
 	if err != nil {
-		switch err := err.(type) {
-		case *net.OpError:
-			if err.Timeout() {
-				return rtt, false, nil
-			}
-			if sysErr, ok := err.Err.(*os.SyscallError); ok {
-				if errno, ok := sysErr.Err.(syscall.Errno); ok {
-					if errno == syscall.ECONNABORTED || errno == syscall.ECONNRESET || errno == syscall.ECONNREFUSED || errno == syscall.ENETUNREACH || errno == syscall.EHOSTUNREACH || errno == syscall.EHOSTDOWN ||
-						errno == syscall.Errno(10053) || errno == syscall.Errno(10054) || errno == syscall.Errno(10061) || errno == syscall.Errno(10060) {
-						return rtt, false, nil
-					}
-				}
-			}
-		case *url.Error:
-			if err, ok := err.Err.(net.Error); ok && err.Timeout() {
-				return rtt, false, nil
-			}
-			if opErr, ok := err.Err.(*net.OpError); ok {
-				if sysErr, ok := opErr.Err.(*os.SyscallError); ok {
-					if errno, ok := sysErr.Err.(syscall.Errno); ok {
-						if errno == syscall.ECONNABORTED || errno == syscall.ECONNRESET || errno == syscall.ECONNREFUSED || errno == syscall.ENETUNREACH || errno == syscall.EHOSTUNREACH || errno == syscall.EHOSTDOWN ||
-							errno == syscall.Errno(10053) || errno == syscall.Errno(10054) || errno == syscall.Errno(10061) || errno == syscall.Errno(10060) {
-							return rtt, false, nil
-						}
-					}
-				}
-			}
-		case net.Error:
-			if err.Timeout() {
-				return rtt, false, nil
-			}
+		if isUnreachableError(err) {
+			return rtt, false, nil
 		}
 		return rtt, false, err
 	} else {
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PushChecker handles passive heartbeat monitors added as push://name.
+// Jobs report to /api/push/<token> and the host goes down if no heartbeat
+// arrives within the host interval plus grace time.
+type PushChecker struct{}
+
+func init() {
+	RegisterChecker("push", PushChecker{})
+}
+
+// PushMonitor is the secret token of a push host. Grace is added to the
+// host interval (in seconds, zero means Config.Checks.PushGrace).
+type PushMonitor struct {
+	Host     string    `json:"host"`
+	Token    string    `json:"token"`
+	Grace    int64     `json:"grace,omitempty"`
+	Created  time.Time `json:"created"`
+	LastPing time.Time `json:"last_ping"`
+}
+
+var pushNameRegex = regexp.MustCompile(`^[[:alnum:]][[:alnum:]._\-]{0,63}$`)
+
+// Heartbeats find their host in pushTokens. It is keyed by SHA-256 of the
+// token, so the map lookup does not depend on the token itself, and the
+// token is then compared in constant time. It is loaded on the first
+// heartbeat and updated when tokens change.
+var pushTokens map[[sha256.Size]byte]string
+var pushTokenHosts map[string][sha256.Size]byte
+var pushTokensMux sync.Mutex
+
+// pushState keeps the time of the last heartbeat (or creation of the
+// monitor) and of the last missed heartbeat result of a push host, so the
+// scheduler can tell whether a host is overdue without reading the DB every
+// tick. Results are saved through the check write buffer, so the last stored
+// check may be older than reported. Hosts without a state are loaded from
+// the DB on their next check.
+type pushState struct {
+	since    time.Time
+	lastPing time.Time
+	grace    time.Duration
+	reported time.Time
+}
+
+var pushStates = make(map[string]pushState)
+var pushStatesMux sync.Mutex
+
+func isPushHost(host string) bool {
+	return getCheckScheme(host) == "push"
+}
+
+// isPushDue reports whether the check of a push host should run at t: its
+// heartbeat is overdue and the miss was not reported within the interval.
+func isPushDue(host string, s HostSettings, t time.Time) bool {
+	pushStatesMux.Lock()
+	st, ok := pushStates[host]
+	pushStatesMux.Unlock()
+	if !ok {
+		return true
+	}
+	if t.Before(st.since.Add(s.IntervalDuration() + st.grace)) {
+		return false
+	}
+	return t.Sub(st.reported) >= s.IntervalDuration()
+}
+
+// setPushPing updates the heartbeat time and grace of a loaded state.
+// Hosts without a state get it on the next check.
+func setPushPing(m PushMonitor) {
+	pushStatesMux.Lock()
+	defer pushStatesMux.Unlock()
+	st, ok := pushStates[m.Host]
+	if !ok {
+		return
+	}
+	st.since = m.LastPing
+	if st.since.IsZero() {
+		st.since = m.Created
+	}
+	st.lastPing = m.LastPing
+	st.grace = m.GraceDuration()
+	pushStates[m.Host] = st
+}
+
+func newPushToken() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (m PushMonitor) GraceDuration() time.Duration {
+	if m.Grace <= 0 {
+		return time.Duration(Config.Checks.PushGrace) * time.Second
+	}
+	return time.Duration(m.Grace) * time.Second
+}
+
+// createPushMonitor creates a monitor with a new token for a push host.
+func createPushMonitor(host string) (m PushMonitor, err error) {
+	m.Host = host
+	m.Created = time.Now().UTC()
+	m.Token, err = newPushToken()
+	if err != nil {
+		return m, err
+	}
+	err = MonData.SetPushMonitor(m)
+	if err != nil {
+		return m, err
+	}
+	indexPushToken(m)
+	return m, nil
+}
+
+// getPushMonitor returns the monitor of a push host and creates it if the
+// host has none (for example if it was restored from an old backup).
+func getPushMonitor(host string) (m PushMonitor, err error) {
+	m, err = MonData.GetPushMonitor(host)
+	if err == ErrNoHostInDB {
+		return createPushMonitor(host)
+	}
+	return m, err
+}
+
+// indexPushToken updates the token of a monitor in pushTokens.
+func indexPushToken(m PushMonitor) {
+	pushTokensMux.Lock()
+	defer pushTokensMux.Unlock()
+	if pushTokens == nil {
+		return
+	}
+	if key, ok := pushTokenHosts[m.Host]; ok {
+		delete(pushTokens, key)
+	}
+	key := sha256.Sum256([]byte(m.Token))
+	pushTokens[key] = m.Host
+	pushTokenHosts[m.Host] = key
+}
+
+// forgetPushHost removes a deleted host from pushTokens and pushStates.
+func forgetPushHost(host string) {
+	pushTokensMux.Lock()
+	if key, ok := pushTokenHosts[host]; ok {
+		delete(pushTokens, key)
+		delete(pushTokenHosts, host)
+	}
+	pushTokensMux.Unlock()
+	pushStatesMux.Lock()
+	delete(pushStates, host)
+	pushStatesMux.Unlock()
+}
+
+// reloadPushTokens makes the next heartbeat load all tokens from the DB
+// and next checks load states of push hosts. It is used after monitors are
+// restored from a backup.
+func reloadPushTokens() {
+	pushTokensMux.Lock()
+	pushTokens = nil
+	pushTokenHosts = nil
+	pushTokensMux.Unlock()
+	pushStatesMux.Lock()
+	pushStates = make(map[string]pushState)
+	pushStatesMux.Unlock()
+}
+
+func getPushMonitorByToken(token string) (m PushMonitor, err error) {
+	key := sha256.Sum256([]byte(token))
+	pushTokensMux.Lock()
+	if pushTokens == nil {
+		var list []PushMonitor
+		list, err = MonData.GetPushMonitorsList()
+		if err != nil {
+			pushTokensMux.Unlock()
+			return m, err
+		}
+		pushTokens = make(map[[sha256.Size]byte]string)
+		pushTokenHosts = make(map[string][sha256.Size]byte)
+		for _, pm := range list {
+			k := sha256.Sum256([]byte(pm.Token))
+			pushTokens[k] = pm.Host
+			pushTokenHosts[pm.Host] = k
+		}
+	}
+	host, ok := pushTokens[key]
+	pushTokensMux.Unlock()
+	if !ok {
+		return m, ErrNoHostInDB
+	}
+	m, err = MonData.GetPushMonitor(host)
+	if err != nil {
+		return m, err
+	}
+	if subtle.ConstantTimeCompare([]byte(m.Token), []byte(token)) != 1 {
+		return m, ErrNoHostInDB
+	}
+	return m, nil
+}
+
+func (c PushChecker) Validate(host string) bool {
+	return pushNameRegex.MatchString(stripCheckScheme(host))
+}
+
+// Check does not contact anything. It reports the host as down if the last
+// heartbeat is overdue. Missed heartbeat is reported once per host interval.
+func (c PushChecker) Check(host string, s HostSettings) (res ChecksData, err error) {
+	pushStatesMux.Lock()
+	st, ok := pushStates[host]
+	pushStatesMux.Unlock()
+	if !ok {
+		st, err = loadPushState(host)
+		if err != nil {
+			return res, err
+		}
+	}
+	now := time.Now().UTC()
+	if now.Before(st.since.Add(s.IntervalDuration()+st.grace)) || now.Sub(st.reported) < s.IntervalDuration() {
+		return res, ErrNoCheckResult
+	}
+	pushStatesMux.Lock()
+	cur, ok := pushStates[host]
+	//The host was deleted or a heartbeat arrived during the check
+	if !ok || cur.since.After(st.since) {
+		pushStatesMux.Unlock()
+		return res, ErrNoCheckResult
+	}
+	cur.reported = now
+	pushStates[host] = cur
+	pushStatesMux.Unlock()
+	if st.lastPing.IsZero() {
+		return res, errors.New("no heartbeat received")
+	}
+	return res, errors.New("no heartbeat since " + st.lastPing.In(ChecksTZ).Format("2006-01-02 15:04:05 MST"))
+}
+
+// loadPushState reads the state of a push host from its monitor and the
+// last stored check and keeps it in pushStates.
+func loadPushState(host string) (st pushState, err error) {
+	m, err := getPushMonitor(host)
+	if err != nil {
+		return st, err
+	}
+	st.since = m.LastPing
+	if st.since.IsZero() {
+		st.since = m.Created
+	}
+	st.lastPing = m.LastPing
+	st.grace = m.GraceDuration()
+	//After a restart the last stored check is used
+	last, e := MonData.GetLastCheckData(host)
+	if e == nil {
+		st.reported = last.Timestamp
+	}
+	pushStatesMux.Lock()
+	if cur, ok := pushStates[host]; ok {
+		st = cur
+	} else {
+		pushStates[host] = st
+	}
+	pushStatesMux.Unlock()
+	return st, nil
+}
+
+// parsePushData reads an optional status (up or down), duration of the job
+// in milliseconds and message from form or query values.
+func parsePushData(get func(key string) string) (cData ChecksData, err error) {
+	cData.Up = true
+	switch strings.ToLower(get("status")) {
+	case "", "up", "ok", "success":
+	case "down", "fail", "error":
+		cData.Up = false
+	default:
+		return cData, errors.New("Bad status")
+	}
+	if v := get("duration"); len(v) > 0 {
+		var d float64
+		d, err = strconv.ParseFloat(v, 64)
+		if err != nil || d < 0 {
+			return cData, errors.New("Bad duration")
+		}
+		cData.Rtt = int64(d * float64(time.Millisecond))
+	}
+	if !cData.Up {
+		cData.Reason = get("msg")
+		if len(cData.Reason) > 1024 {
+			cData.Reason = cData.Reason[:1024]
+		}
+		if len(cData.Reason) == 0 {
+			cData.Reason = "job failed"
+		}
+	}
+	return cData, nil
+}
+
+// savePush stores a heartbeat as a check of the host. It goes through the
+// same write buffer as results of active checks.
+func savePush(m PushMonitor, cData ChecksData) error {
+	cData.Timestamp = time.Now().UTC()
+	cData.Maintenance = inMaintenance(m.Host, cData.Timestamp)
+	metricsObserveCheck(m.Host, cData)
+	publishCheckEvent(m.Host, cData)
+
+	m.LastPing = cData.Timestamp
+	err := MonData.SetPushMonitor(m)
+	if err != nil {
+		return err
+	}
+	setPushPing(m)
+	go checkStateChange(m.Host, cData)
+	queueCheckSave(m.Host, cData)
+	return nil
+}
+
+const JsonPushHandlerEndpoint string = "/api/push/"
+
+// JsonPushHandler receives heartbeats at /api/push/<token>. The token
+// authenticates the request.
+func JsonPushHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimPrefix(r.URL.Path, JsonPushHandlerEndpoint)
+	if len(token) == 0 || strings.Contains(token, "/") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	m, err := getPushMonitorByToken(token)
+	if err != nil {
+		if err == ErrNoHostInDB {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("[ERROR] %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	cData, err := parsePushData(r.FormValue)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	//Heartbeats of paused hosts are not stored, like results of active checks
+	paused, err := isHostPaused(m.Host, time.Now().UTC())
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if paused {
+		http.Error(w, "Host is paused", http.StatusConflict)
+		return
+	}
+
+	err = savePush(m, cData)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = w.Write([]byte("OK"))
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+}
+
+// PushMonitorUpdate changes the grace time or generates a new token.
+type PushMonitorUpdate struct {
+	Host       string `json:"host"`
+	Grace      int64  `json:"grace"`
+	ResetToken bool   `json:"reset_token,omitempty"`
+}
+
+func UpdatePushMonitor(u PushMonitorUpdate) (m PushMonitor, err error) {
+	if !isPushHost(u.Host) {
+		return m, errors.New("Not a push host")
+	}
+	if u.Grace < 0 {
+		return m, errors.New("Bad grace")
+	}
+	err = MonData.CheckHostExists(u.Host)
+	if err != nil {
+		return m, err
+	}
+	m, err = getPushMonitor(u.Host)
+	if err != nil {
+		return m, err
+	}
+	m.Grace = u.Grace
+	if u.ResetToken {
+		m.Token, err = newPushToken()
+		if err != nil {
+			return m, err
+		}
+	}
+	err = MonData.SetPushMonitor(m)
+	if err != nil {
+		return m, err
+	}
+	indexPushToken(m)
+	setPushPing(m)
+	return m, nil
+}
+
+const JsonPushMonitorsHandlerEndpoint string = "/api/push_monitors"
+
+// JsonPushMonitorsHandler lists push monitors with their tokens and updates
+// them. It always requires authentication if it is enabled.
+func JsonPushMonitorsHandler(w http.ResponseWriter, r *http.Request) {
+	if Config.Listen.WebAuth.Enable {
+		username, password, authOK := r.BasicAuth()
+		if authOK == false {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("401 - Not authorized"))
+			return
+		}
+
+		if username != Config.Listen.WebAuth.User || password != Config.Listen.WebAuth.Password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("401 - Not authorized"))
+			return
+		}
+	}
+
+	var jsonData []byte
+	switch r.Method {
+	case http.MethodGet:
+		list, err := MonData.GetPushMonitorsList()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		jsonData, err = json.Marshal(list)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+	case http.MethodPut:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		var u PushMonitorUpdate
+		err = json.Unmarshal(body, &u)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		m, err := UpdatePushMonitor(u)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		jsonData, err = json.Marshal(m)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err := w.Write(jsonData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
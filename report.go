@@ -0,0 +1,357 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ChecksStats aggregate checks of a host over a period. Rtt values are
+// calculated from successful checks only (in nanoseconds).
+type ChecksStats struct {
+	Checks   int64 `json:"checks"`
+	UpChecks int64 `json:"up_checks"`
+	RttMin   int64 `json:"rtt_min"`
+	RttAvg   int64 `json:"rtt_avg"`
+	RttP50   int64 `json:"rtt_p50"`
+	RttP95   int64 `json:"rtt_p95"`
+	RttP99   int64 `json:"rtt_p99"`
+	RttMax   int64 `json:"rtt_max"`
+}
+
+// MonthlyChecks is the number of checks of a host in a calendar month (UTC).
+type MonthlyChecks struct {
+	Host     string
+	Month    time.Time
+	Checks   int64
+	UpChecks int64
+}
+
+type Report struct {
+	Host  string    `json:"host"`
+	Name  string    `json:"name,omitempty"`
+	Group string    `json:"group,omitempty"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	ChecksStats
+	Uptime    float64 `json:"uptime"`
+	Downtime  int64   `json:"downtime"`
+	Incidents int64   `json:"incidents"`
+	MTTR      int64   `json:"mttr"`
+	MTBF      int64   `json:"mtbf"`
+}
+
+type MonthlySLA struct {
+	Month     string  `json:"month"`
+	Checks    int64   `json:"checks"`
+	Uptime    float64 `json:"uptime"`
+	Downtime  int64   `json:"downtime"`
+	Incidents int64   `json:"incidents"`
+}
+
+type HostSLA struct {
+	Host   string       `json:"host"`
+	Name   string       `json:"name,omitempty"`
+	Group  string       `json:"group,omitempty"`
+	Months []MonthlySLA `json:"months"`
+}
+
+// percentileRtt returns the nearest-rank percentile of sorted values.
+func percentileRtt(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+// computeChecksStats is used by backends that can not aggregate checks
+// in the DB. Checks during maintenance are not counted.
+func computeChecksStats(cData []ChecksData) (s ChecksStats) {
+	var rtts []int64
+	var sum int64 = 0
+	for _, c := range cData {
+		if c.Maintenance {
+			continue
+		}
+		s.Checks++
+		if c.Up {
+			s.UpChecks++
+			rtts = append(rtts, c.Rtt)
+			sum += c.Rtt
+		}
+	}
+	if len(rtts) == 0 {
+		return s
+	}
+	sort.Slice(rtts, func(i, j int) bool {
+		return rtts[i] < rtts[j]
+	})
+	s.RttMin = rtts[0]
+	s.RttMax = rtts[len(rtts)-1]
+	s.RttAvg = sum / int64(len(rtts))
+	s.RttP50 = percentileRtt(rtts, 0.5)
+	s.RttP95 = percentileRtt(rtts, 0.95)
+	s.RttP99 = percentileRtt(rtts, 0.99)
+	return s
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// computeMonthlyChecks is used by backends that can not aggregate checks
+// in the DB.
+func computeMonthlyChecks(db MonDB, start time.Time, end time.Time) (m []MonthlyChecks, err error) {
+	hosts, err := db.GetHostsList()
+	if err != nil {
+		return m, err
+	}
+	for _, host := range hosts {
+		for month := monthStart(start); !month.After(end); month = month.AddDate(0, 1, 0) {
+			s := month
+			if s.Before(start) {
+				s = start
+			}
+			e := month.AddDate(0, 1, 0).Add(-time.Nanosecond)
+			if e.After(end) {
+				e = end
+			}
+			var cData []ChecksData
+			cData, err = db.GetChecksData(ChecksRequest{Host: host, Start: s, End: e})
+			if err != nil {
+				return m, err
+			}
+			stats := computeChecksStats(cData)
+			if stats.Checks == 0 {
+				continue
+			}
+			m = append(m, MonthlyChecks{Host: host, Month: month, Checks: stats.Checks, UpChecks: stats.UpChecks})
+		}
+	}
+	return m, nil
+}
+
+// incidentDowntime returns the part of the incident inside the period.
+func incidentDowntime(i Incident, start time.Time, end time.Time) time.Duration {
+	s := i.Start
+	if s.Before(start) {
+		s = start
+	}
+	e := time.Now().UTC()
+	if i.End != nil {
+		e = *i.End
+	}
+	if e.After(end) {
+		e = end
+	}
+	if e.Before(s) {
+		return 0
+	}
+	return e.Sub(s)
+}
+
+func uptimePercent(checks int64, upChecks int64) float64 {
+	if checks == 0 {
+		return 0
+	}
+	return float64(upChecks) * 100 / float64(checks)
+}
+
+func getReport(req ChecksRequest) (r Report, err error) {
+	r.Host = req.Host
+	meta := getHostMeta(req.Host)
+	r.Name = meta.Name
+	r.Group = meta.Group
+	r.Start = req.Start
+	r.End = req.End
+
+	r.ChecksStats, err = MonData.GetChecksStats(req.Host, req.Start, req.End)
+	if err != nil {
+		return r, err
+	}
+	r.Uptime = uptimePercent(r.Checks, r.UpChecks)
+
+	incidents, err := MonData.GetIncidents(req.Host, req.Start, req.End)
+	if err != nil {
+		return r, err
+	}
+	var downtime, repairTime time.Duration
+	var repaired int64 = 0
+	for _, i := range incidents {
+		downtime += incidentDowntime(i, req.Start, req.End)
+		if i.End != nil {
+			repairTime += i.End.Sub(i.Start)
+			repaired++
+		}
+	}
+	r.Incidents = int64(len(incidents))
+	r.Downtime = int64(downtime.Seconds())
+	if repaired > 0 {
+		r.MTTR = int64(repairTime.Seconds()) / repaired
+	}
+	if r.Incidents > 0 {
+		r.MTBF = int64((req.End.Sub(req.Start) - downtime).Seconds()) / r.Incidents
+	}
+	return r, nil
+}
+
+// getMonthlySLA returns uptime of hosts that match the filter for every
+// month of the period.
+func getMonthlySLA(start time.Time, end time.Time, f HostFilter) (sla []HostSLA, err error) {
+	sla = make([]HostSLA, 0)
+	months, err := MonData.GetMonthlyChecks(start, end)
+	if err != nil {
+		return sla, err
+	}
+	meta, err := getHostMetaMap()
+	if err != nil {
+		return sla, err
+	}
+	incidents, err := MonData.GetIncidents("", start, end)
+	if err != nil {
+		return sla, err
+	}
+
+	hostsIdx := make(map[string]int)
+	for _, m := range months {
+		hm := meta[m.Host]
+		if !f.Match(hm.HostMetaFields) {
+			continue
+		}
+		idx, ok := hostsIdx[m.Host]
+		if !ok {
+			idx = len(sla)
+			hostsIdx[m.Host] = idx
+			sla = append(sla, HostSLA{Host: m.Host, Name: hm.Name, Group: hm.Group})
+		}
+		ms := m.Month
+		if ms.Before(start) {
+			ms = start
+		}
+		me := m.Month.AddDate(0, 1, 0)
+		if me.After(end) {
+			me = end
+		}
+		month := MonthlySLA{
+			Month:  m.Month.Format("2006-01"),
+			Checks: m.Checks,
+			Uptime: uptimePercent(m.Checks, m.UpChecks),
+		}
+		var downtime time.Duration
+		for _, i := range incidents {
+			if i.Host != m.Host {
+				continue
+			}
+			downtime += incidentDowntime(i, ms, me)
+			if !i.Start.Before(ms) && i.Start.Before(me) {
+				month.Incidents++
+			}
+		}
+		month.Downtime = int64(downtime.Seconds())
+		sla[idx].Months = append(sla[idx].Months, month)
+	}
+
+	sort.Slice(sla, func(i, j int) bool {
+		return sla[i].Host < sla[j].Host
+	})
+	for _, h := range sla {
+		sort.Slice(h.Months, func(i, j int) bool {
+			return h.Months[i].Month < h.Months[j].Month
+		})
+	}
+	return sla, nil
+}
+
+const JsonReportHandlerEndpoint string = "/api/report"
+
+func JsonReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := getIncidentsRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Host) == 0 {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	err = MonData.CheckHostExists(req.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := getReport(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonData, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(jsonData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+const JsonSLAHandlerEndpoint string = "/api/report/sla"
+
+// JsonSLAHandler returns monthly uptime of all hosts for the last months
+// (12 by default) including the current one. Hosts can be filtered by group
+// and tag.
+func JsonSLAHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var months int64 = 12
+	var err error
+	if m := r.URL.Query().Get("months"); len(m) > 0 {
+		months, err = strconv.ParseInt(m, 10, 64)
+		if err != nil || months < 1 || months > 120 {
+			http.Error(w, "Bad months", http.StatusBadRequest)
+			return
+		}
+	}
+	end := time.Now().UTC()
+	start := monthStart(end).AddDate(0, -int(months-1), 0)
+
+	sla, err := getMonthlySLA(start, end, getHostFilter(r.URL.Query().Get))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonData, err := json.Marshal(sla)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(jsonData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// Raw checks are periodically aggregated to hourly and daily rollups.
+// Hourly rollups are calculated from raw checks and daily rollups from
+// hourly ones, so rollups outlive raw checks removed by retention.
+// Buckets are aligned to UTC.
+
+const (
+	ResolutionRaw  string = "raw"
+	ResolutionHour string = "hour"
+	ResolutionDay  string = "day"
+)
+
+// RollupData aggregates checks of a host in a time bucket starting at
+// Timestamp. Rtt values are calculated from successful checks only.
+type RollupData struct {
+	Timestamp time.Time `json:"time"`
+	Up        int64     `json:"up"`
+	Down      int64     `json:"down"`
+	RttMin    int64     `json:"rtt_min"`
+	RttAvg    int64     `json:"rtt_avg"`
+	RttMax    int64     `json:"rtt_max"`
+	rttSum    int64
+}
+
+const rollupsInterval = 5 * time.Minute
+
+// rollupsChunk limits the number of rows loaded at once while rolling up.
+var rollupsChunk = map[string]time.Duration{
+	ResolutionHour: 24 * time.Hour,
+	ResolutionDay:  31 * 24 * time.Hour,
+}
+
+func isValidResolution(resolution string) bool {
+	switch resolution {
+	case "", ResolutionRaw, ResolutionHour, ResolutionDay:
+		return true
+	}
+	return false
+}
+
+func isRollupResolution(resolution string) bool {
+	return resolution == ResolutionHour || resolution == ResolutionDay
+}
+
+func resolutionDuration(resolution string) time.Duration {
+	switch resolution {
+	case ResolutionHour:
+		return time.Hour
+	case ResolutionDay:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+func (r *RollupData) addCheck(c ChecksData) {
+	if !c.Up {
+		r.Down++
+		return
+	}
+	if r.Up == 0 || c.Rtt < r.RttMin {
+		r.RttMin = c.Rtt
+	}
+	if c.Rtt > r.RttMax {
+		r.RttMax = c.Rtt
+	}
+	r.rttSum += c.Rtt
+	r.Up++
+	r.RttAvg = r.rttSum / r.Up
+}
+
+func (r *RollupData) addRollup(o RollupData) {
+	r.Down += o.Down
+	if o.Up == 0 {
+		return
+	}
+	if r.Up == 0 || o.RttMin < r.RttMin {
+		r.RttMin = o.RttMin
+	}
+	if o.RttMax > r.RttMax {
+		r.RttMax = o.RttMax
+	}
+	r.rttSum += o.RttAvg * o.Up
+	r.Up += o.Up
+	r.RttAvg = r.rttSum / r.Up
+}
+
+// ChecksData represents the bucket as a single check. The bucket is down if
+// any of the checks failed.
+func (r RollupData) ChecksData() ChecksData {
+	return ChecksData{Timestamp: r.Timestamp, Rtt: r.RttAvg, Up: r.Down == 0}
+}
+
+// rollupChecks aggregates checks to buckets of dt. Checks during
+// maintenance are skipped.
+func rollupChecks(cData []ChecksData, dt time.Duration) (r []RollupData) {
+	idx := make(map[time.Time]int)
+	for _, c := range cData {
+		if c.Maintenance {
+			continue
+		}
+		ts := c.Timestamp.UTC().Truncate(dt)
+		n, ok := idx[ts]
+		if !ok {
+			n = len(r)
+			idx[ts] = n
+			r = append(r, RollupData{Timestamp: ts})
+		}
+		r[n].addCheck(c)
+	}
+	return r
+}
+
+func rollupRollups(rData []RollupData, dt time.Duration) (r []RollupData) {
+	idx := make(map[time.Time]int)
+	for _, o := range rData {
+		ts := o.Timestamp.UTC().Truncate(dt)
+		n, ok := idx[ts]
+		if !ok {
+			n = len(r)
+			idx[ts] = n
+			r = append(r, RollupData{Timestamp: ts})
+		}
+		r[n].addRollup(o)
+	}
+	return r
+}
+
+// rollupsStart returns the first bucket of host that has to be rolled up.
+func rollupsStart(host string, resolution string) (start time.Time, ok bool, err error) {
+	dt := resolutionDuration(resolution)
+	last, err := MonData.GetLastRollup(host, resolution)
+	if err != nil {
+		return start, false, err
+	}
+	if !last.Timestamp.IsZero() {
+		return last.Timestamp.UTC().Add(dt), true, nil
+	}
+	first, err := MonData.GetFirstCheckData(host)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return start, false, nil
+		}
+		return start, false, err
+	}
+	if first.Timestamp.IsZero() {
+		return start, false, nil
+	}
+	return first.Timestamp.UTC().Truncate(dt), true, nil
+}
+
+// updateHostRollups rolls up all complete buckets of host up to now.
+func updateHostRollups(host string, resolution string, now time.Time) error {
+	dt := resolutionDuration(resolution)
+	start, ok, err := rollupsStart(host, resolution)
+	if err != nil || !ok {
+		return err
+	}
+	//Checks of the last minutes may still be running
+	end := now.UTC().Add(-rollupsInterval).Truncate(dt)
+	for s := start; s.Before(end) && doProcess; s = s.Add(rollupsChunk[resolution]) {
+		e := s.Add(rollupsChunk[resolution])
+		if e.After(end) {
+			e = end
+		}
+		chkReq := ChecksRequest{Host: host, Start: s, End: e.Add(-time.Nanosecond)}
+		var r []RollupData
+		if resolution == ResolutionHour {
+			var cData []ChecksData
+			cData, err = MonData.GetChecksData(chkReq)
+			if err != nil {
+				return err
+			}
+			r = rollupChecks(cData, dt)
+		} else {
+			chkReq.Resolution = ResolutionHour
+			var hData []RollupData
+			hData, err = MonData.GetRollups(chkReq)
+			if err != nil {
+				return err
+			}
+			r = rollupRollups(hData, dt)
+		}
+		if len(r) == 0 {
+			continue
+		}
+		err = MonData.SaveRollups(host, resolution, r)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func updateRollups() {
+	hosts, err := MonData.GetHostsList()
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		return
+	}
+	now := time.Now().UTC()
+	for _, host := range hosts {
+		//Daily rollups depend on hourly ones
+		for _, resolution := range []string{ResolutionHour, ResolutionDay} {
+			if !doProcess {
+				return
+			}
+			err = updateHostRollups(host, resolution, now)
+			if err != nil {
+				log.Printf("[ERROR] rollup of %v: %v", host, err)
+				break
+			}
+		}
+	}
+}
+
+// rollupsWorker keeps rollups up to date until shutdown.
+func rollupsWorker() {
+	for doProcess {
+		updateRollups()
+		select {
+		case <-ctx.Done():
+			return
+		case <-shutdownChan:
+			return
+		case <-time.After(rollupsInterval):
+		}
+	}
+}
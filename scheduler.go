@@ -0,0 +1,213 @@
+package main
+
+import (
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+)
+
+// Checks are performed by a fixed pool of workers. The scheduler ticks
+// every step, queues hosts that are due and skips hosts whose previous
+// check is still queued or running (overrun).
+
+type checkJob struct {
+	host      string
+	checkTime time.Time
+	settings  HostSettings
+}
+
+var checkJobs chan checkJob
+
+var checksPending = make(map[string]bool)
+var checksPendingMux sync.Mutex
+
+// The list of hosts is cached between ticks and reloaded at most once per
+// scheduleRefreshInterval.
+const scheduleRefreshInterval = 10 * time.Second
+
+type scheduledHost struct {
+	host     string
+	settings HostSettings
+	jitter   time.Duration
+}
+
+var schedule []scheduledHost
+var schedulePaused map[string]HostPause
+var scheduleLoaded time.Time
+var scheduleLastTick time.Time
+var scheduleMux sync.Mutex
+
+func startCheckWorkers(n int64) {
+	checkJobs = make(chan checkJob, n*4)
+	for i := int64(0); i < n; i++ {
+		go checkWorker()
+	}
+}
+
+func checkWorker() {
+	for j := range checkJobs {
+		//Queued checks are dropped on shutdown
+		if doProcess {
+			doCheck(j.host, j.checkTime, j.settings)
+		}
+		checksPendingMux.Lock()
+		delete(checksPending, j.host)
+		checksPendingMux.Unlock()
+		wg.Done()
+	}
+}
+
+// hostJitter returns the offset of host checks inside its interval. It
+// depends only on the host name, so checks of a host are always performed
+// at the same time within the interval.
+func hostJitter(host string, interval time.Duration) time.Duration {
+	if !Config.Checks.SpreadChecks {
+		return 0
+	}
+	seconds := int64(interval / time.Second)
+	if seconds <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(host))
+	return time.Duration(int64(h.Sum32())%seconds) * time.Second
+}
+
+// isCheckDue reports whether a check of host is scheduled after prev and
+// not later than t. Ticks that were skipped because the scheduler woke up
+// late are covered by the next tick. If prev is zero only t is checked.
+func (h scheduledHost) isCheckDue(prev time.Time, t time.Time) bool {
+	//Push monitors can become overdue at any time
+	if isPushHost(h.host) {
+		return isPushDue(h.host, h.settings, t)
+	}
+	interval := h.settings.IntervalDuration()
+	if prev.IsZero() {
+		t = t.Add(-h.jitter)
+		return t.Truncate(interval).Equal(t)
+	}
+	next := prev.Add(-h.jitter).Truncate(interval).Add(interval).Add(h.jitter)
+	return !next.After(t)
+}
+
+// getTickStep returns how often the scheduler should tick. When checks are
+// spread each host can be due at any second.
+func getTickStep() time.Duration {
+	if Config.Checks.SpreadChecks {
+		return time.Second
+	}
+	return getScheduleStep()
+}
+
+func loadSchedule() error {
+	hosts, err := MonData.GetHostsList()
+	if err != nil {
+		return err
+	}
+	settings, err := getHostSettingsMap(hosts)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+	paused, err := getPausedHostsMap()
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+	list := make([]scheduledHost, 0, len(hosts))
+	for _, host := range hosts {
+		s := settings[host]
+		list = append(list, scheduledHost{host: host, settings: s, jitter: hostJitter(host, s.IntervalDuration())})
+	}
+	schedule = list
+	schedulePaused = paused
+	return nil
+}
+
+// refreshSchedule reloads the schedule if it is older than
+// scheduleRefreshInterval. scheduleMux must be held.
+func refreshSchedule() error {
+	if time.Since(scheduleLoaded) < scheduleRefreshInterval {
+		return nil
+	}
+	err := loadSchedule()
+	if err != nil {
+		return err
+	}
+	scheduleLoaded = time.Now()
+	return nil
+}
+
+// isHostPaused reports whether host is paused at t. It uses the pauses
+// cached for the scheduler.
+func isHostPaused(host string, t time.Time) (bool, error) {
+	scheduleMux.Lock()
+	defer scheduleMux.Unlock()
+	err := refreshSchedule()
+	if err != nil {
+		return false, err
+	}
+	p, ok := schedulePaused[host]
+	return ok && p.isPaused(t), nil
+}
+
+// reloadSchedule makes the next tick reload hosts and their settings.
+func reloadSchedule() {
+	scheduleMux.Lock()
+	scheduleLoaded = time.Time{}
+	scheduleMux.Unlock()
+}
+
+func checkTick(t time.Time) {
+	if !doProcess {
+		return
+	}
+	scheduleMux.Lock()
+	err := refreshSchedule()
+	if err != nil {
+		scheduleMux.Unlock()
+		log.Printf("[ERROR] %v", err)
+		return
+	}
+	//Ticks run in goroutines, a late one is covered by the newer tick
+	if !t.After(scheduleLastTick) {
+		scheduleMux.Unlock()
+		return
+	}
+	prev := scheduleLastTick
+	scheduleLastTick = t
+	var due []scheduledHost
+	var resumed []string
+	for _, h := range schedule {
+		if p, ok := schedulePaused[h.host]; ok {
+			if p.isPaused(t) {
+				continue
+			}
+			delete(schedulePaused, h.host)
+			resumed = append(resumed, h.host)
+		}
+		if h.isCheckDue(prev, t) {
+			due = append(due, h)
+		}
+	}
+	scheduleMux.Unlock()
+
+	//Resume is a DB write, so it is done without holding scheduleMux
+	resumeExpiredPauses(resumed)
+
+	for _, h := range due {
+		checksPendingMux.Lock()
+		if checksPending[h.host] {
+			checksPendingMux.Unlock()
+			//Push checks are evaluated every tick, so they are not overruns
+			if !isPushHost(h.host) {
+				log.Printf("[WARNING] previous check of %v is still running, check skipped", h.host)
+				metricsObserveSchedulerOverrun()
+			}
+			continue
+		}
+		checksPending[h.host] = true
+		checksPendingMux.Unlock()
+		wg.Add(1)
+		checkJobs <- checkJob{host: h.host, checkTime: t, settings: h.settings}
+	}
+}
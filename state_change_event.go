@@ -1,62 +1,232 @@
 package main
 
 import (
+	"database/sql"
+	"encoding/json"
 	"log"
+	"net/http"
+	"sort"
 	"sync"
 	"time"
 )
 
+// StateChangeParams describe when and how a host state change is reported.
+// Action is the request URL. If Body is set it is rendered as a text/template
+// and sent with Method (POST by default).
 type StateChangeParams struct {
-	Host            string `json:"host"`
-	ChangeThreshold int64  `json:"threshold"`
-	Action          string `json:"action"`
+	Host            string            `json:"host"`
+	ChangeThreshold int64             `json:"threshold"`
+	Action          string            `json:"action"`
+	Method          string            `json:"method,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	Body            string            `json:"body,omitempty"`
 }
 
+// StateChangeData is the tracked state of a host. State changes only after
+// ChangeThreshold consecutive checks with the other result. Since is the time
+// when the host entered the current state.
 type StateChangeData struct {
+	Host             string    `json:"host"`
 	LastTimeObserved time.Time `json:"observed"`
 	State            bool      `json:"state"`
 	ChangeCount      int64     `json:"count"`
+	Since            time.Time `json:"since"`
 }
 
 var CheckStates map[string]StateChangeData = make(map[string]StateChangeData)
 var CheckStatesMux sync.RWMutex
 
-func checkStateChange(host string, rtt int64, checkTime time.Time, up bool) {
+// How far back checks are read to find when the current state started if
+// the state was not stored.
+const stateRebuildPeriod = 7 * 24 * time.Hour
+
+// setCheckState updates the cached state and stores it if anything but the
+// observation time has changed.
+func setCheckState(s StateChangeData, old StateChangeData, stored bool) {
+	CheckStatesMux.Lock()
+	CheckStates[s.Host] = s
+	CheckStatesMux.Unlock()
+	if stored && s.State == old.State && s.ChangeCount == old.ChangeCount && s.Since.Equal(old.Since) {
+		return
+	}
+	err := MonData.SetHostState(s)
+	if err != nil && err != ErrNoHostInDB {
+		log.Printf("[ERROR] %v", err)
+	}
+}
+
+// rebuildCheckState restores the state of a host from the stored checks.
+func rebuildCheckState(host string) (s StateChangeData, ok bool, err error) {
+	var last ChecksData
+	last, err = MonData.GetLastCheckData(host)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = nil
+		}
+		return s, false, err
+	}
+	if last.Timestamp.IsZero() {
+		return s, false, nil
+	}
+	s = StateChangeData{Host: host, LastTimeObserved: last.Timestamp, State: last.Up, Since: last.Timestamp}
+
+	var cData []ChecksData
+	cData, err = MonData.GetChecksData(ChecksRequest{Host: host, Start: last.Timestamp.Add(-stateRebuildPeriod), End: last.Timestamp})
+	if err != nil {
+		return s, true, err
+	}
+	sort.Slice(cData, func(i, j int) bool {
+		return cData[i].Timestamp.Before(cData[j].Timestamp)
+	})
+	for i := len(cData) - 1; i >= 0; i-- {
+		if cData[i].Up != last.Up {
+			break
+		}
+		s.Since = cData[i].Timestamp
+	}
+	return s, true, nil
+}
+
+// loadCheckStates fills CheckStates from the DB. States of hosts that were
+// not stored are rebuilt from the last checks.
+func loadCheckStates() error {
+	states, err := MonData.GetHostStatesList()
+	if err != nil {
+		return err
+	}
+	CheckStatesMux.Lock()
+	for _, s := range states {
+		CheckStates[s.Host] = s
+	}
+	CheckStatesMux.Unlock()
+
+	hosts, err := MonData.GetHostsList()
+	if err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		CheckStatesMux.RLock()
+		_, ok := CheckStates[host]
+		CheckStatesMux.RUnlock()
+		if ok {
+			continue
+		}
+		s, ok, err := rebuildCheckState(host)
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+		}
+		if ok {
+			setCheckState(s, StateChangeData{}, false)
+		}
+	}
+	return nil
+}
+
+func checkStateChange(host string, cData ChecksData) {
+	//State is kept as it was before the maintenance, so the change is
+	//reported if the host is still down after it
+	if cData.Maintenance {
+		return
+	}
+	checkTime := cData.Timestamp
+	up := cData.Up
+	//Hosts without notifications are tracked with threshold of 1
+	var notify bool = true
 	checkParams, err := MonData.GetHostStateChangeParams(host)
 	if err != nil {
 		if err != ErrNoHostInDB {
 			log.Printf("[ERROR] %v", err)
+			return
 		}
-		return
+		notify = false
+		checkParams = StateChangeParams{Host: host, ChangeThreshold: 1}
+	}
+	if len(checkParams.Action) == 0 {
+		notify = false
 	}
 	CheckStatesMux.RLock()
 	checkState, ok := CheckStates[host]
 	CheckStatesMux.RUnlock()
 	if !ok {
-		CheckStatesMux.Lock()
-		CheckStates[host] = StateChangeData{checkTime, up, 0}
-		CheckStatesMux.Unlock()
+		newState := StateChangeData{host, checkTime, up, 0, checkTime}
+		setCheckState(newState, checkState, false)
+		publishStateEvent(newState)
 		return
 	}
 	if checkState.State == up {
-		CheckStatesMux.Lock()
-		CheckStates[host] = StateChangeData{checkTime, checkState.State, 0}
-		CheckStatesMux.Unlock()
+		setCheckState(StateChangeData{host, checkTime, checkState.State, 0, checkState.Since}, checkState, true)
 		return
 	} else {
 		newCount := checkState.ChangeCount + 1
 		if newCount >= checkParams.ChangeThreshold {
-			CheckStatesMux.Lock()
-			CheckStates[host] = StateChangeData{checkTime, up, 0}
-			CheckStatesMux.Unlock()
-			err = EventHTTPNotify(host, rtt, checkTime, up, checkParams.Action)
+			newState := StateChangeData{host, checkTime, up, 0, checkTime}
+			setCheckState(newState, checkState, true)
+			publishStateEvent(newState)
+			//Incidents are built from the stored checks before this one
+			flushCheckWrites()
+			if up {
+				closeIncident(host, cData)
+			} else {
+				openIncident(host, cData, checkParams.ChangeThreshold)
+			}
+			if !notify {
+				return
+			}
+			err = QueueEventNotification(host, cData, checkParams, checkTime.Sub(checkState.Since))
 			if err != nil {
 				log.Printf("[ERROR] %v", err)
 			}
 		} else {
-			CheckStatesMux.Lock()
-			CheckStates[host] = StateChangeData{checkTime, checkState.State, newCount}
-			CheckStatesMux.Unlock()
+			setCheckState(StateChangeData{host, checkTime, checkState.State, newCount, checkState.Since}, checkState, true)
 		}
 	}
 }
+
+const JsonStatesHandlerEndpoint string = "/api/states"
+
+func JsonStatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	hosts, err := MonData.GetHostsList()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	hosts, err = filterHosts(hosts, getHostFilter(r.URL.Query().Get))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	host := r.URL.Query().Get("host")
+	states := make([]StateChangeData, 0)
+	CheckStatesMux.RLock()
+	for _, h := range hosts {
+		if len(host) > 0 && h != host {
+			continue
+		}
+		if s, ok := CheckStates[h]; ok {
+			states = append(states, s)
+		}
+	}
+	CheckStatesMux.RUnlock()
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Host < states[j].Host
+	})
+
+	jsonData, err := json.Marshal(states)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(jsonData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -61,7 +61,7 @@ func JsonStateChangeParamsHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		err = MonData.AddHostStateChangeParams(newParams.Host, newParams.ChangeThreshold, newParams.Action)
+		err = AddStateChangeParams(newParams)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -28,6 +28,18 @@ const stateChangeParamsTemplateDoc string = `<!DOCTYPE html>
   <p><input name="threshold" type="number"></p>
   <p>Action:</p>
   <p><input name="state_action" type="text"></p>
+  <p>Method (GET if empty, POST if body is set):</p>
+  <p><select name="method">
+    <option value=""></option>
+    <option value="GET">GET</option>
+    <option value="POST">POST</option>
+    <option value="PUT">PUT</option>
+    <option value="PATCH">PATCH</option>
+  </select></p>
+  <p>Headers (JSON object):</p>
+  <p><input name="headers" type="text"></p>
+  <p>Body template:</p>
+  <p><textarea name="body" rows="4" cols="60"></textarea></p>
   <p><input type="submit" value="Add"></p>
 </form>
 
@@ -45,6 +57,9 @@ const stateChangeParamsTemplateDoc string = `<!DOCTYPE html>
 	<th>Host</a></th>
 	<th>Threshold</th>
 	<th>Action</th>
+	<th>Method</th>
+	<th>Headers</th>
+	<th>Body</th>
 	<th>Delete</th>
   </tr>
 {{range .Params}}
@@ -52,6 +67,9 @@ const stateChangeParamsTemplateDoc string = `<!DOCTYPE html>
 	<td><a href="` + HostsViewTemplateHandlerEndpoint + `?host={{.Host}}">{{.Host}}</a></td>
 	<td>{{.ChangeThreshold}}</td>
 	<td>{{.Action}}</td>
+	<td>{{.Method}}</td>
+	<td>{{range $k, $v := .Headers}}{{$k}}: {{$v}}<br>{{end}}</td>
+	<td><pre>{{.Body}}</pre></td>
 	<td><form action="` + StateChangeParamsHandlerEndpoint + `" method="post">
 	  <input type="hidden" name="action" value="del">
 	  <input type="hidden" name="host" value="{{.Host}}">
@@ -143,7 +161,20 @@ func StateChangeParamsTemplateHandler(w http.ResponseWriter, r *http.Request) {
 				http.Error(w, "Bad request", http.StatusBadRequest)
 				return
 			}
-			err = MonData.AddHostStateChangeParams(newHost, checkThreshold, newAction)
+			var headers map[string]string
+			headers, err = HeadersFromString(r.PostFormValue("headers"))
+			if err != nil {
+				http.Error(w, "Bad headers", http.StatusBadRequest)
+				return
+			}
+			err = AddStateChangeParams(StateChangeParams{
+				Host:            newHost,
+				ChangeThreshold: checkThreshold,
+				Action:          newAction,
+				Method:          r.PostFormValue("method"),
+				Headers:         headers,
+				Body:            r.PostFormValue("body"),
+			})
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// The public status page shows configured components instead of hosts.
+// A component can be mapped to several hosts. It is served without
+// authentication, so host names, reasons and other details are not shown.
+
+type StatusPageComponent struct {
+	Name  string
+	Hosts []string
+}
+
+const (
+	componentOperational string = "operational"
+	componentDegraded    string = "degraded"
+	componentOutage      string = "outage"
+	componentUnknown     string = "unknown"
+)
+
+// How long resolved incidents are shown.
+const statusPageIncidentsPeriod = 14 * 24 * time.Hour
+
+type StatusPageDay struct {
+	Date   time.Time
+	Checks int64
+	Up     int64
+}
+
+func (d StatusPageDay) Uptime() float64 {
+	return uptimePercent(d.Checks, d.Up)
+}
+
+type StatusPageComponentData struct {
+	Name   string
+	Status string
+	Checks int64
+	Up     int64
+	Days   []StatusPageDay
+}
+
+func (c StatusPageComponentData) Uptime() float64 {
+	return uptimePercent(c.Checks, c.Up)
+}
+
+type StatusPageIncident struct {
+	Component string
+	Start     time.Time
+	End       *time.Time
+	Duration  int64
+}
+
+type StatusPageData struct {
+	Title      string
+	Status     string
+	Updated    time.Time
+	Components []StatusPageComponentData
+	Incidents  []StatusPageIncident
+}
+
+// getHostStatusDays returns checks count of host for every day starting from
+// start. Days that are not rolled up yet are calculated from raw checks.
+func getHostStatusDays(host string, start time.Time, now time.Time) (days map[time.Time]StatusPageDay, err error) {
+	days = make(map[time.Time]StatusPageDay)
+	today := now.UTC().Truncate(24 * time.Hour)
+
+	rData, err := MonData.GetRollups(ChecksRequest{Host: host, Start: start, End: now, Resolution: ResolutionDay})
+	if err != nil {
+		return days, err
+	}
+	rawFrom := today.AddDate(0, 0, -1)
+	for _, r := range rData {
+		days[r.Timestamp] = StatusPageDay{Date: r.Timestamp, Checks: r.Up + r.Down, Up: r.Up}
+		if !r.Timestamp.Before(rawFrom) {
+			rawFrom = r.Timestamp.AddDate(0, 0, 1)
+		}
+	}
+
+	for d := rawFrom; !d.After(today); d = d.AddDate(0, 0, 1) {
+		var s ChecksStats
+		s, err = MonData.GetChecksStats(host, d, d.AddDate(0, 0, 1).Add(-time.Nanosecond))
+		if err != nil {
+			return days, err
+		}
+		if s.Checks > 0 {
+			days[d] = StatusPageDay{Date: d, Checks: s.Checks, Up: s.UpChecks}
+		}
+	}
+	return days, nil
+}
+
+func getComponentStatus(hosts []string) string {
+	var up, down int = 0, 0
+	CheckStatesMux.RLock()
+	for _, h := range hosts {
+		s, ok := CheckStates[h]
+		if !ok {
+			continue
+		}
+		if s.State {
+			up++
+		} else {
+			down++
+		}
+	}
+	CheckStatesMux.RUnlock()
+	switch {
+	case up == 0 && down == 0:
+		return componentUnknown
+	case down == 0:
+		return componentOperational
+	case up == 0:
+		return componentOutage
+	}
+	return componentDegraded
+}
+
+func getStatusPageData() (data StatusPageData, err error) {
+	now := time.Now().UTC()
+	data.Title = Config.StatusPage.Title
+	data.Updated = now.In(ChecksTZ)
+	data.Status = componentOperational
+
+	today := now.Truncate(24 * time.Hour)
+	start := today.AddDate(0, 0, -int(Config.StatusPage.Days-1))
+	componentsOfHost := make(map[string][]string)
+	for _, c := range Config.StatusPage.Components {
+		cd := StatusPageComponentData{Name: c.Name, Status: getComponentStatus(c.Hosts)}
+		if cd.Status != componentOperational && cd.Status != componentUnknown {
+			data.Status = componentDegraded
+		}
+
+		total := make(map[time.Time]StatusPageDay)
+		for _, h := range c.Hosts {
+			componentsOfHost[h] = append(componentsOfHost[h], c.Name)
+			var days map[time.Time]StatusPageDay
+			days, err = getHostStatusDays(h, start, now)
+			if err != nil {
+				return data, err
+			}
+			for t, d := range days {
+				td := total[t]
+				td.Checks += d.Checks
+				td.Up += d.Up
+				total[t] = td
+			}
+		}
+		for d := start; !d.After(today); d = d.AddDate(0, 0, 1) {
+			day := total[d]
+			day.Date = d
+			cd.Checks += day.Checks
+			cd.Up += day.Up
+			cd.Days = append(cd.Days, day)
+		}
+		data.Components = append(data.Components, cd)
+	}
+
+	incidents, err := MonData.GetIncidents("", now.Add(-statusPageIncidentsPeriod), now)
+	if err != nil {
+		return data, err
+	}
+	for _, i := range incidents {
+		i.setDuration()
+		for _, c := range componentsOfHost[i.Host] {
+			si := StatusPageIncident{Component: c, Start: i.Start.In(ChecksTZ), Duration: i.Duration}
+			if i.End != nil {
+				end := i.End.In(ChecksTZ)
+				si.End = &end
+			}
+			data.Incidents = append(data.Incidents, si)
+		}
+	}
+	sort.SliceStable(data.Incidents, func(i, j int) bool {
+		return data.Incidents[i].Start.After(data.Incidents[j].Start)
+	})
+	return data, nil
+}
+
+const statusPageTemplateDoc string = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="UTF-8">
+  <meta http-equiv="refresh" content="60">
+  <title>{{.Title}}</title>
+  <style>
+    body {font-family: sans-serif; max-width: 60em; margin: auto;}
+    .component {margin-bottom: 2em;}
+    .bars {display: flex; height: 2em;}
+    .bar {flex: 1; margin-right: 1px;}
+    .operational, .good {background: #66cc66;}
+    .degraded, .warn {background: #ffcc33;}
+    .outage, .bad {background: #ff6666;}
+    .unknown, .nodata {background: #cccccc;}
+    .status {padding: 0.2em 0.5em;}
+    td {padding-right: 1em;}
+  </style>
+</head>
+
+<body>
+
+<h1>{{.Title}}</h1>
+<p class="status {{.Status}}">{{if eq .Status "operational"}}All systems operational{{else}}Some systems are experiencing problems{{end}}</p>
+
+{{range .Components}}
+<div class="component">
+  <h3>{{.Name}} <span class="status {{.Status}}">{{.Status}}</span></h3>
+  <div class="bars">
+  {{range .Days}}<div class="bar {{dayClass .}}" title="{{.Date.Format "2006-01-02"}}{{if .Checks}} {{printf "%.2f" .Uptime}}%{{else}} no data{{end}}"></div>{{end}}
+  </div>
+  <p>{{len .Days}} days{{if .Checks}}, uptime {{printf "%.2f" .Uptime}}%{{end}}</p>
+</div>
+{{end}}
+
+<h2>Incidents</h2>
+{{if .Incidents}}
+<table id="incidents">
+  <tr>
+	<th>Component</th>
+	<th>Start</th>
+	<th>End</th>
+	<th>Duration</th>
+  </tr>
+{{range .Incidents}}
+  <tr>
+	<td>{{.Component}}</td>
+	<td>{{.Start.Format "2006-01-02 15:04:05 MST"}}</td>
+	<td>{{if .End}}{{.End.Format "2006-01-02 15:04:05 MST"}}{{else}}ongoing{{end}}</td>
+	<td>{{duration .Duration}}</td>
+  </tr>
+{{end}}
+</table>
+{{else}}
+<p>No incidents reported.</p>
+{{end}}
+
+<p>Updated {{.Updated.Format "2006-01-02 15:04:05 MST"}}</p>
+
+</body>
+</html>
+`
+
+var statusPageTemplate = template.Must(template.New("Status Page Template").Funcs(template.FuncMap{
+	"duration": func(s int64) string {
+		return (time.Duration(s) * time.Second).String()
+	},
+	"dayClass": func(d StatusPageDay) string {
+		switch {
+		case d.Checks == 0:
+			return "nodata"
+		case d.Uptime() >= 99.9:
+			return "good"
+		case d.Uptime() >= 99:
+			return "warn"
+		}
+		return "bad"
+	},
+}).Parse(statusPageTemplateDoc))
+
+// StatusPageHandler is served at Config.StatusPage.Path without
+// authentication.
+func StatusPageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := getStatusPageData()
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	err = statusPageTemplate.Execute(w, data)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+}
@@ -2,13 +2,40 @@ package main
 
 import (
 	"net"
-	"net/url"
-	"os"
-	"syscall"
+	"strconv"
+	"strings"
 	"time"
 )
 
-func TcpCheck(host string) (rtt int64, up bool, err error) {
+type TcpChecker struct{}
+
+func init() {
+	RegisterChecker("tcp", TcpChecker{})
+}
+
+func (TcpChecker) Validate(host string) bool {
+	host = stripCheckScheme(host)
+	var h []string
+	if strings.HasPrefix(host, "[") {
+		h = strings.Split(host[1:], "]:")
+	} else {
+		h = strings.Split(host, ":")
+	}
+	if len(h) != 2 || !isHostOrIP(h[0]) {
+		return false
+	}
+	i, err := strconv.ParseInt(h[1], 10, 32)
+	if err != nil {
+		return false
+	}
+	return i > 0 && i <= 65535
+}
+
+func (TcpChecker) Check(host string, s HostSettings) (res ChecksData, err error) {
+	return TcpCheck(stripCheckScheme(host), s.TimeoutDuration())
+}
+
+func TcpCheck(host string, timeout time.Duration) (res ChecksData, err error) {
 	network := "tcp"
 	if false {
 		network = "tcp4"
@@ -18,44 +45,18 @@ func TcpCheck(host string) (rtt int64, up bool, err error) {
 
 	start := time.Now()
 	var conn net.Conn
-	conn, err = net.DialTimeout(network, host, time.Duration(Config.Checks.Timeout)*time.Second)
-	rtt = int64(time.Since(start))
+	conn, err = net.DialTimeout(network, host, timeout)
+	res.Rtt = int64(time.Since(start))
 	if err != nil {
-		switch err := err.(type) {
-		case *net.OpError:
-			if err.Timeout() {
-				return rtt, false, nil
-			}
-			if sysErr, ok := err.Err.(*os.SyscallError); ok {
-				if errno, ok := sysErr.Err.(syscall.Errno); ok {
-					if errno == syscall.ECONNABORTED || errno == syscall.ECONNRESET || errno == syscall.ECONNREFUSED || errno == syscall.ENETUNREACH || errno == syscall.EHOSTUNREACH || errno == syscall.EHOSTDOWN ||
-						errno == syscall.Errno(10053) || errno == syscall.Errno(10054) || errno == syscall.Errno(10061) || errno == syscall.Errno(10060) {
-						return rtt, false, nil
-					}
-				}
-			}
-		case *url.Error:
-			if err, ok := err.Err.(net.Error); ok && err.Timeout() {
-				return rtt, false, nil
-			}
-			if opErr, ok := err.Err.(*net.OpError); ok {
-				if sysErr, ok := opErr.Err.(*os.SyscallError); ok {
-					if errno, ok := sysErr.Err.(syscall.Errno); ok {
-						if errno == syscall.ECONNABORTED || errno == syscall.ECONNRESET || errno == syscall.ECONNREFUSED || errno == syscall.ENETUNREACH || errno == syscall.EHOSTUNREACH || errno == syscall.EHOSTDOWN ||
-							errno == syscall.Errno(10053) || errno == syscall.Errno(10054) || errno == syscall.Errno(10061) || errno == syscall.Errno(10060) {
-							return rtt, false, nil
-						}
-					}
-				}
-			}
-		case net.Error:
-			if err.Timeout() {
-				return rtt, false, nil
-			}
+		res.Reason = err.Error()
+		if isUnreachableError(err) {
+			return res, nil
 		}
-		return rtt, false, err
+		return res, err
 	}
+	res.IP = addrIP(conn.RemoteAddr())
+	res.Up = true
 	err = conn.Close()
 
-	return rtt, true, err
+	return res, err
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TlsChecker performs a TLS handshake with tls://host[:port] and validates
+// the peer certificate.
+type TlsChecker struct{}
+
+func init() {
+	RegisterChecker("tls", TlsChecker{})
+}
+
+type CertificateInfo struct {
+	Host     string    `json:"host"`
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+	DNSNames []string  `json:"dns_names"`
+	Checked  time.Time `json:"checked"`
+	Valid    bool      `json:"valid"`
+	Error    string    `json:"error,omitempty"`
+}
+
+var CertStates map[string]CertificateInfo = make(map[string]CertificateInfo)
+var CertStatesMux sync.RWMutex
+
+func tlsHostAddr(host string) string {
+	addr := stripCheckScheme(host)
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), "443")
+	}
+	return addr
+}
+
+func (TlsChecker) Validate(host string) bool {
+	return TcpChecker{}.Validate(tlsHostAddr(host))
+}
+
+func (TlsChecker) Check(host string, s HostSettings) (res ChecksData, err error) {
+	return TlsCheck(host, tlsHostAddr(host), s.TimeoutDuration())
+}
+
+func TlsCheck(host string, addr string, timeout time.Duration) (res ChecksData, err error) {
+	serverName, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return res, err
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	start := time.Now()
+	var conn *tls.Conn
+	//Certificate is verified separately to keep its details when validation fails
+	conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+	res.Rtt = int64(time.Since(start))
+	if err != nil {
+		CertStatesMux.Lock()
+		CertStates[host] = CertificateInfo{Host: host, Checked: time.Now().UTC(), Valid: false, Error: err.Error()}
+		CertStatesMux.Unlock()
+		res.Reason = err.Error()
+		return res, nil
+	}
+	defer conn.Close()
+	res.IP = addrIP(conn.RemoteAddr())
+
+	info := checkCertificate(host, serverName, conn.ConnectionState())
+	res.Up = info.Valid
+	res.Reason = info.Error
+	return res, nil
+}
+
+// checkCertificate validates the chain, host name and expiry of the peer
+// certificate and records the result in CertStates.
+func checkCertificate(host string, serverName string, state tls.ConnectionState) (info CertificateInfo) {
+	info.Host = host
+	info.Checked = time.Now().UTC()
+	defer func() {
+		CertStatesMux.Lock()
+		CertStates[host] = info
+		CertStatesMux.Unlock()
+	}()
+
+	if len(state.PeerCertificates) == 0 {
+		info.Error = "no peer certificates"
+		return info
+	}
+	leaf := state.PeerCertificates[0]
+	info.Subject = leaf.Subject.String()
+	info.Issuer = leaf.Issuer.String()
+	info.NotAfter = leaf.NotAfter.UTC()
+	info.DNSNames = leaf.DNSNames
+	for _, ip := range leaf.IPAddresses {
+		info.DNSNames = append(info.DNSNames, ip.String())
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range state.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{DNSName: serverName, Intermediates: intermediates})
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	expiryLimit := time.Duration(Config.Checks.CertExpiryDays) * 24 * time.Hour
+	if time.Until(leaf.NotAfter) < expiryLimit {
+		info.Error = fmt.Sprintf("certificate expires at %v", leaf.NotAfter.UTC())
+		return info
+	}
+
+	info.Valid = true
+	return info
+}
+
+const JsonCertificatesHandlerEndpoint string = "/api/certificates"
+
+func JsonCertificatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	host := r.URL.Query().Get("host")
+	certs := make([]CertificateInfo, 0)
+	CertStatesMux.RLock()
+	for h, c := range CertStates {
+		if len(host) == 0 || h == host {
+			certs = append(certs, c)
+		}
+	}
+	CertStatesMux.RUnlock()
+	sort.Slice(certs, func(i, j int) bool {
+		return certs[i].Host < certs[j].Host
+	})
+
+	jsonData, err := json.Marshal(certs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(jsonData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}